@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/victhorio/jambe-verte/internal/cache"
+	"github.com/victhorio/jambe-verte/internal/config"
+	"github.com/victhorio/jambe-verte/internal/content"
+	"github.com/victhorio/jambe-verte/internal/export"
+	"github.com/victhorio/jambe-verte/internal/handlers"
+)
+
+// runBuild exports the whole site as static files that can be hosted on plain object
+// storage. Every route goes through the same handlers as jv-server, so the output is
+// byte-identical to what the server would send.
+func runBuild(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	outDir := fs.String("out", "public", "directory to write the static site to")
+	baseURL := fs.String("base-url", "", "public origin of the mirror (overrides site.baseURL)")
+	fs.Parse(args)
+
+	if *baseURL != "" {
+		cfg.Site.BaseURL = *baseURL
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Site.BaseURL == "" {
+		return errors.New("build needs a base URL for feeds and sitemaps: set site.baseURL or pass -base-url")
+	}
+
+	// Static mirrors never include drafts, since there's no way to protect them
+	cfg.Content.Drafts = false
+
+	posts, err := content.LoadContent(cfg.Content.PostsDir, true, content.LoadOptions{Workers: cfg.Content.Workers, Strict: cfg.Content.Strict})
+	if err != nil {
+		return fmt.Errorf("loading posts: %w", err)
+	}
+	pages, err := content.LoadContent(cfg.Content.PagesDir, false, content.LoadOptions{Workers: cfg.Content.Workers, Strict: cfg.Content.Strict})
+	if err != nil {
+		return fmt.Errorf("loading pages: %w", err)
+	}
+
+	// Redirect rules are left out, since static hosts have their own way of redirecting
+	c, err := handlers.NewSnapshot(cache.Sources{Posts: content.Loaded{Posts: posts}, Pages: content.Loaded{Posts: pages}}, cfg.Content.Strict)
+	if err != nil {
+		return err
+	}
+	h, err := handlers.New(c, cfg, false)
+	if err != nil {
+		return fmt.Errorf("parsing templates: %w", err)
+	}
+	r := chi.NewRouter()
+	h.Routes(r)
+
+	ctx := context.Background()
+	content.RebuildCSS(ctx)
+
+	routes := h.KnownRoutes(c)
+	if err := export.Routes(ctx, r, cfg.Site.BaseURL, routes, *outDir); err != nil {
+		return err
+	}
+	if err := export.CopyDir("static", filepath.Join(*outDir, "static")); err != nil {
+		return fmt.Errorf("copying static files: %w", err)
+	}
+
+	fmt.Printf("Built %d routes into %s\n", len(routes), *outDir)
+	return nil
+}
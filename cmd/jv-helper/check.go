@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/victhorio/jambe-verte/internal/cache"
+	"github.com/victhorio/jambe-verte/internal/config"
+	"github.com/victhorio/jambe-verte/internal/content"
+	"github.com/victhorio/jambe-verte/internal/handlers"
+	"github.com/victhorio/jambe-verte/internal/redirects"
+)
+
+// runCheck validates every post, page and redirect rule the same way a strict
+// jv-server would, including slug conflicts, listing every problem at once so they
+// can be fixed before deploying. Drafts are validated too, even though they won't be
+// published yet.
+func runCheck(cfg *config.Config) error {
+	var errs []error
+	posts, err := content.LoadContent(cfg.Content.PostsDir, true, content.LoadOptions{IncludeDrafts: true, Workers: cfg.Content.Workers, Strict: true})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	pages, err := content.LoadContent(cfg.Content.PagesDir, false, content.LoadOptions{Workers: cfg.Content.Workers, Strict: true})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	rules, err := redirects.LoadFile(cfg.Content.Redirects, true)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		// Slugs are checked across posts and pages, so it needs both to have loaded
+		_, err := handlers.NewSnapshot(cache.Sources{Posts: content.Loaded{Posts: posts}, Pages: content.Loaded{Posts: pages}}, true)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		fileErrs := content.FileErrors(err)
+		if len(fileErrs) == 0 {
+			return err
+		}
+		for _, fileErr := range fileErrs {
+			fmt.Println(fileErr)
+		}
+		return fmt.Errorf("found %d problem(s)", len(fileErrs))
+	}
+
+	fmt.Printf("OK: %d posts, %d pages and %d redirect rules\n", len(posts), len(pages), rules.Len())
+	return nil
+}
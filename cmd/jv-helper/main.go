@@ -1,45 +1,74 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/victhorio/jambe-verte/internal/config"
 )
 
+const usage = `Usage:
+  jv-helper [-config path] <post|page> <slug>
+  jv-helper [-config path] build [-out dir] [-base-url url]
+  jv-helper [-config path] check`
+
 func main() {
-	if len(os.Args) != 3 {
-		fmt.Println("Usage: jv-helper <post|page> <slug>")
+	configPath := flag.String("config", config.PathFromEnv(), "path to the site configuration file")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		fmt.Println(usage)
 		os.Exit(1)
 	}
 
-	contentType := os.Args[1]
-	slug := os.Args[2]
+	cfg, err := config.Load(*configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	if contentType != "post" && contentType != "page" {
-		fmt.Println("Error: content type must be 'post' or 'page'")
+	switch command := flag.Arg(0); command {
+	case "post", "page":
+		if flag.NArg() != 2 {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		slug := flag.Arg(1)
+		if err = createContent(cfg, command, slug); err == nil {
+			fmt.Printf("Created %s: %s\n", command, slug)
+		}
+	case "build":
+		err = runBuild(cfg, flag.Args()[1:])
+	case "check":
+		err = runCheck(cfg)
+	default:
+		fmt.Printf("Error: unknown command '%s'\n%s\n", command, usage)
 		os.Exit(1)
 	}
 
-	if err := createContent(contentType, slug); err != nil {
+	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	fmt.Printf("Created %s: %s\n", contentType, slug)
 }
 
-func createContent(contentType, slug string) error {
+func createContent(cfg *config.Config, contentType, slug string) error {
 	var dir, filename, content string
 	today := time.Now().Format("2006-01-02")
 
 	switch contentType {
 	case "post":
-		dir = "content/posts"
+		dir = cfg.Content.PostsDir
 		filename = fmt.Sprintf("%s-%s.md", today, slug)
 		content = createPostContent(slug, today)
 	case "page":
-		dir = "content/pages"
+		dir = cfg.Content.PagesDir
 		filename = fmt.Sprintf("%s.md", slug)
 		content = createPageContent(slug, today)
 	}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,13 +12,26 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/victhorio/jambe-verte/internal/cache"
+	"github.com/victhorio/jambe-verte/internal/config"
 	"github.com/victhorio/jambe-verte/internal/content"
 	"github.com/victhorio/jambe-verte/internal/handlers"
+	"github.com/victhorio/jambe-verte/internal/listener"
 	"github.com/victhorio/jambe-verte/internal/logger"
+	"github.com/victhorio/jambe-verte/internal/metrics"
 	mymiddleware "github.com/victhorio/jambe-verte/internal/middleware"
+	"github.com/victhorio/jambe-verte/internal/redirects"
 )
 
 func main() {
+	configPath := flag.String("config", config.PathFromEnv(), "path to the site configuration file")
+	addr := flag.String("addr", "", "TCP address or unix:/path/to.sock to listen on (overrides server.address)")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file (overrides server.tls.certFile)")
+	tlsKey := flag.String("tls-key", "", "TLS key file (overrides server.tls.keyFile)")
+	postsDir := flag.String("posts", "", "directory containing posts (overrides content.postsDir)")
+	pagesDir := flag.String("pages", "", "directory containing pages (overrides content.pagesDir)")
+	drafts := flag.Bool("drafts", false, "load draft posts for signed previews (overrides content.drafts)")
+	flag.Parse()
+
 	// Check if debug mode is enabled
 	debugMode := os.Getenv("JV_DEBUG") == "1"
 	logger.Init(debugMode)
@@ -25,25 +39,72 @@ func main() {
 		logger.Logger.Warn("===== Debug mode enabled =====")
 	}
 
-	// Load posts
-	posts, err := content.LoadContent("content/posts", true)
+	// Load configuration, with flags taking precedence over file and environment
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Logger.Error("Error loading config", "error", err)
+		os.Exit(1)
+	}
+	if *addr != "" {
+		cfg.Server.Address = *addr
+	}
+	if *tlsCert != "" {
+		cfg.Server.TLS.CertFile = *tlsCert
+	}
+	if *tlsKey != "" {
+		cfg.Server.TLS.KeyFile = *tlsKey
+	}
+	if *postsDir != "" {
+		cfg.Content.PostsDir = *postsDir
+	}
+	if *pagesDir != "" {
+		cfg.Content.PagesDir = *pagesDir
+	}
+	if *drafts {
+		cfg.Content.Drafts = true
+	}
+	if err := cfg.Validate(); err != nil {
+		logger.Logger.Error("Error validating config", "error", err)
+		os.Exit(1)
+	}
+
+	// Load posts, keeping the file records around so that refreshes only re-render
+	// files that changed
+	posts, _, err := content.Reload(cfg.Content.PostsDir, true, content.LoadOptions{IncludeDrafts: cfg.Content.Drafts, Workers: cfg.Content.Workers, Strict: cfg.Content.Strict}, nil)
 	if err != nil {
 		logger.Logger.Error("Error loading posts", "error", err)
 		os.Exit(1)
 	}
 
 	// Load pages
-	pages, err := content.LoadContent("content/pages", false)
+	pages, _, err := content.Reload(cfg.Content.PagesDir, false, content.LoadOptions{Workers: cfg.Content.Workers, Strict: cfg.Content.Strict}, nil)
 	if err != nil {
 		logger.Logger.Error("Error loading pages", "error", err)
 		os.Exit(1)
 	}
 
+	// Load redirect rules
+	rules, err := redirects.LoadFile(cfg.Content.Redirects, cfg.Content.Strict)
+	if err != nil {
+		logger.Logger.Error("Error loading redirects", "error", err)
+		os.Exit(1)
+	}
+
 	// Create cache
-	c := cache.New(posts, pages)
+	c, err := handlers.NewSnapshot(cache.Sources{
+		Posts:        posts,
+		Pages:        pages,
+		Redirects:    rules,
+		MaxPageBytes: int64(cfg.Cache.MaxBytes),
+		PageStats:    &cache.Stats{},
+	}, cfg.Content.Strict)
+	if err != nil {
+		logger.Logger.Error("Error building content snapshot", "error", err)
+		os.Exit(1)
+	}
 
 	// Create handlers
-	h, err := handlers.New(c, debugMode)
+	h, err := handlers.New(c, cfg, debugMode)
 	if err != nil {
 		logger.Logger.Error("Error parsing templates", "error", err)
 		os.Exit(1)
@@ -55,64 +116,164 @@ func main() {
 	// Middleware
 	r.Use(middleware.RealIP)
 	r.Use(middleware.RequestID)
-	r.Use(mymiddleware.RequestLogger)
+	r.Use(mymiddleware.RequestLogger(metrics.Default))
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
-	r.Use(middleware.Compress(5))
 
-	// Routes
-	r.Get("/", h.Home)
-	r.Get("/posts", h.ListPosts)
-	r.Get("/blog/{slug}", h.ShowPost)
-	r.Get("/tag/{tag}", h.PostsByTag)
-	r.Get("/feed.xml", h.RSSFeed)
-	r.Get("/{page}", h.ShowPage)
+	// Probes for load balancers and process supervisors, which redirect rules and
+	// aliases must never shadow
+	r.Get("/healthz", h.Healthz)
+	r.Get("/readyz", h.Readyz)
+	r.Get("/version", h.Version)
+
+	// Content routes, with redirect rules and aliases taking precedence over them. The
+	// NotFound handler goes through the redirects too, since that's where the paths
+	// of removed or renamed content end up.
+	r.Group(func(r chi.Router) {
+		r.Use(h.Redirects)
+		h.Routes(r)
+		r.Handle("/static/*", http.StripPrefix("/static/", handlers.StaticFiles("static")))
+		r.NotFound(http.NotFound)
+	})
 
 	// Protected admin routes
 	r.Route("/admin", func(r chi.Router) {
 		if !debugMode {
 			r.Use(mymiddleware.AdminAuth)
 		}
+		r.Use(middleware.Compress(5))
 		r.Post("/refresh", h.AdminRefresh)
+		r.Post("/preview", h.AdminPreview)
+		r.Get("/schedule", h.AdminSchedule)
+		r.Get("/cache", h.AdminCache)
 	})
 
-	// Static files
-	fileServer := http.FileServer(http.Dir("static"))
-	r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
+	// Metrics are either served on their own listener or guarded like admin routes
+	h.RegisterMetrics(metrics.Default)
+	var metricsSrv *http.Server
+	if cfg.Server.MetricsAddress != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", metrics.Default.Handler())
+		metricsSrv = &http.Server{
+			Addr:         cfg.Server.MetricsAddress,
+			Handler:      metricsMux,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+		}
+	} else {
+		r.Group(func(r chi.Router) {
+			if !debugMode {
+				r.Use(mymiddleware.AdminAuth)
+			}
+			r.Method(http.MethodGet, "/metrics", metrics.Default.Handler())
+		})
+	}
+
+	// Live reload is a development aid only and must never be exposed in production
+	if debugMode {
+		r.Get("/_jv/livereload", h.LiveReload)
+	}
 
 	// Rebuild CSS on startup for better DX
 	content.RebuildCSS(context.Background())
 
+	// In debug mode, watch content, templates and CSS for changes instead of reloading
+	// everything on every request
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	if debugMode {
+		h.StartHotReload(watchCtx)
+	}
+
 	// Start server with timeouts
-	addr := ":8080"
 	srv := &http.Server{
-		Addr:         addr,
 		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	ln, err := listener.Listen(cfg.Server.Address)
+	if err != nil {
+		logger.Logger.Error("Error listening", "address", cfg.Server.Address, "error", err)
+		os.Exit(1)
+	}
+
+	// Certificates are reloaded on SIGHUP, keeping the current ones if that fails
+	if tlsCfg := cfg.Server.TLS; tlsCfg.Enabled() {
+		certs, err := listener.LoadCertificates(tlsCfg.CertFile, tlsCfg.KeyFile)
+		if err != nil {
+			logger.Logger.Error("Error loading TLS certificate", "error", err)
+			os.Exit(1)
+		}
+		srv.TLSConfig = certs.TLSConfig()
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := certs.Reload(); err != nil {
+					logger.Logger.Error("Failed to reload TLS certificate, keeping the current one", "error", err)
+					continue
+				}
+				logger.Logger.Info("Reloaded TLS certificate", "cert", tlsCfg.CertFile)
+			}
+		}()
 	}
 
 	// Start server in a goroutine
 	go func() {
-		logger.Logger.Info("Starting server", "address", addr)
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Logger.Info("Starting server", "address", ln.Addr().String(), "tls", srv.TLSConfig != nil)
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
+		if err != http.ErrServerClosed {
 			logger.Logger.Error("Server error", "error", err)
 			os.Exit(1)
 		}
 	}()
 
+	if metricsSrv != nil {
+		go func() {
+			logger.Logger.Info("Starting metrics server", "address", metricsSrv.Addr)
+			if err := metricsSrv.ListenAndServe(); err != http.ErrServerClosed {
+				logger.Logger.Error("Metrics server error", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// Warm up the initial snapshot in the background, after which the server reports
+	// ready. Until then, requests are still served and render whatever isn't warm yet.
+	startCtx, stopStarting := context.WithCancel(context.Background())
+	go h.Start(startCtx)
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+	stopStarting()
+
+	// Fail readiness first, so that load balancers stop sending traffic before the
+	// listener goes away
+	h.Drain()
+	if delay := cfg.Server.ShutdownDelay; delay > 0 {
+		logger.Logger.Info("Draining before shutdown", "delay", delay.String())
+		time.Sleep(delay)
+	}
 
 	logger.Logger.Info("Shutting down server...")
 
-	// Give outstanding requests 10 seconds to complete
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Give outstanding requests some time to complete
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
+	if metricsSrv != nil {
+		metricsSrv.Shutdown(shutdownCtx)
+	}
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Logger.Error("Server forced to shutdown", "error", err)
 		os.Exit(1)
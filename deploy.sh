@@ -35,7 +35,7 @@ echo -e "${YELLOW}[3/6] Copying required files...${NC}"
 cp -r templates "$DEPLOY_DIR/"
 cp -r static "$DEPLOY_DIR/"
 cp -r content "$DEPLOY_DIR/"
-cp package.json tailwind.config.js "$DEPLOY_DIR/"
+cp config.yaml package.json tailwind.config.js "$DEPLOY_DIR/"
 
 # Step 4: Create deployment package
 echo -e "${YELLOW}[4/6] Creating deployment package...${NC}"
@@ -1,7 +1,13 @@
 package cache
 
 import (
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/victhorio/jambe-verte/internal/content"
+	"github.com/victhorio/jambe-verte/internal/redirects"
+	"github.com/victhorio/jambe-verte/internal/search"
 )
 
 // Cache is immutable after initialization. Individual Cache instances never change
@@ -9,42 +15,168 @@ import (
 // and replace the old one atomically.
 type Cache struct {
 	posts     map[string]*content.Post
+	drafts    map[string]*content.Post
 	pages     map[string]*content.Post
 	tags      map[string][]*content.Post
 	postsFlat []*content.Post
+	scheduled []*content.Post
 	pageCache *PageCache
+	search    *search.Index
+	// aliases maps old URL paths to the canonical URL of their post or page
+	aliases map[string]string
+	// conflicts lists the content left out because of slug or alias conflicts
+	conflicts []error
+
+	// The content this snapshot was built from, kept around so that Rebuild can
+	// publish scheduled posts without touching the disk, and so that reloads can
+	// reuse the files that didn't change.
+	sources Sources
+}
+
+// Sources is the content a snapshot is built from.
+type Sources struct {
+	Posts content.Loaded
+	Pages content.Loaded
+	// Reserved reports slugs that are taken by routes other than posts and pages, so
+	// that content can't shadow them. Nil reserves nothing.
+	Reserved func(slug string) bool
+	// Redirects are the redirect rules served along with this content. Nil means none.
+	Redirects *redirects.Rules
+
+	// MaxPageBytes is the budget of the snapshot's PageCache, zero meaning unbounded.
+	MaxPageBytes int64
+	// PageStats is shared by the PageCache of every snapshot built from these sources
+	// (or their reloads), so that counters carry on across refreshes. Nil keeps none.
+	PageStats *Stats
 }
 
+// New builds a snapshot from already loaded content. Posts whose PublishAt is still
+// in the future are held back as scheduled until a later snapshot is built.
 func New(posts []*content.Post, pages []*content.Post) *Cache {
+	return NewFromSources(Sources{Posts: content.Loaded{Posts: posts}, Pages: content.Loaded{Posts: pages}})
+}
+
+// NewFromSources works like New, but also keeps the file records of the loaded content
+// so that the next reload can skip unchanged files (see GetSources), and keeps content
+// off reserved slugs. Content with conflicting slugs is left out, see Conflicts.
+func NewFromSources(sources Sources) *Cache {
+	r := resolveSlugs(sources.Posts.Posts, sources.Pages.Posts, sources.Reserved)
+	posts, pages := r.posts, r.pages
+	now := time.Now()
 	c := &Cache{
 		posts:     make(map[string]*content.Post),
+		drafts:    make(map[string]*content.Post),
 		pages:     make(map[string]*content.Post),
 		tags:      make(map[string][]*content.Post),
-		postsFlat: posts,
-		pageCache: NewPageCache(),
+		pageCache: NewPageCache(sources.MaxPageBytes, sources.PageStats),
+		aliases:   make(map[string]string),
+		conflicts: r.conflicts,
+
+		sources: sources,
 	}
 
 	// For each post, index it by slug on `c.posts` and index it
-	// by its tag on `c.tags`
+	// by its tag on `c.tags`. Drafts are set aside on `c.drafts` so that
+	// they never show up in listings, tag pages or feeds, and the same goes for
+	// scheduled posts on `c.scheduled`.
 	for _, post := range posts {
+		if post.Draft {
+			c.drafts[post.Slug] = post
+			continue
+		}
+		if post.PublishAt.After(now) {
+			c.scheduled = append(c.scheduled, post)
+			continue
+		}
 		c.posts[post.Slug] = post
+		c.postsFlat = append(c.postsFlat, post)
+		for _, alias := range post.Aliases {
+			if target, ok := r.aliases[alias]; ok {
+				c.aliases[alias] = target
+			}
+		}
 		for _, tag := range post.Tags {
 			c.tags[tag] = append(c.tags[tag], post)
 		}
 	}
 
+	slices.SortStableFunc(c.scheduled, func(a, b *content.Post) int {
+		return a.PublishAt.Compare(b.PublishAt)
+	})
+
 	// For each page, index it by slug on `c.pages`
 	for _, page := range pages {
 		c.pages[page.Slug] = page
+		for _, alias := range page.Aliases {
+			if target, ok := r.aliases[alias]; ok {
+				c.aliases[alias] = target
+			}
+		}
 	}
+
+	// Build the full-text index over published posts only, so that it's swapped
+	// together with the rest of the snapshot.
+	c.search = search.New(c.postsFlat)
 	return c
 }
 
+// Rebuild returns a fresh snapshot from the same content, publishing any scheduled
+// posts that came due in the meantime. The new snapshot starts with an empty PageCache.
+func (c *Cache) Rebuild() *Cache {
+	return NewFromSources(c.sources)
+}
+
+// GetSources returns the content this snapshot was built from, including drafts and
+// scheduled posts, so that a reload can reuse whatever didn't change.
+func (c *Cache) GetSources() Sources {
+	return c.sources
+}
+
+// Conflicts returns a content.FileError for every post, page or alias that was left
+// out because its slug or URL was already taken.
+func (c *Cache) Conflicts() []error {
+	return c.conflicts
+}
+
+// GetRedirect returns the redirect rule matching `path`, see redirects.Rules.Match.
+func (c *Cache) GetRedirect(path string) (target string, status int, ok bool) {
+	if c.sources.Redirects == nil {
+		return "", 0, false
+	}
+	return c.sources.Redirects.Match(path)
+}
+
+// GetAlias returns where an old URL path of a published post or page redirects to.
+func (c *Cache) GetAlias(path string) (string, bool) {
+	target, ok := c.aliases[path]
+	return target, ok
+}
+
+// GetScheduled returns the posts waiting to be published, soonest first.
+func (c *Cache) GetScheduled() []*content.Post {
+	return c.scheduled
+}
+
+// NextPublishAt returns when the next scheduled post comes due, if there is any.
+func (c *Cache) NextPublishAt() (time.Time, bool) {
+	if len(c.scheduled) == 0 {
+		return time.Time{}, false
+	}
+	return c.scheduled[0].PublishAt, true
+}
+
 func (c *Cache) GetPost(slug string) (*content.Post, bool) {
 	post, ok := c.posts[slug]
 	return post, ok
 }
 
+// GetDraft returns a draft post. Drafts are only present when content was loaded
+// with drafts included.
+func (c *Cache) GetDraft(slug string) (*content.Post, bool) {
+	draft, ok := c.drafts[slug]
+	return draft, ok
+}
+
 func (c *Cache) GetPage(slug string) (*content.Post, bool) {
 	page, ok := c.pages[slug]
 	return page, ok
@@ -54,10 +186,36 @@ func (c *Cache) GetPostsByTag(tag string) []*content.Post {
 	return c.tags[tag]
 }
 
+// GetTags returns every tag used by a published post, sorted alphabetically.
+func (c *Cache) GetTags() []string {
+	tags := make([]string, 0, len(c.tags))
+	for tag := range c.tags {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// GetPages returns every page, sorted by slug.
+func (c *Cache) GetPages() []*content.Post {
+	pages := make([]*content.Post, 0, len(c.pages))
+	for _, page := range c.pages {
+		pages = append(pages, page)
+	}
+	slices.SortFunc(pages, func(a, b *content.Post) int {
+		return strings.Compare(a.Slug, b.Slug)
+	})
+	return pages
+}
+
 func (c *Cache) GetPosts() []*content.Post {
 	return c.postsFlat
 }
 
+func (c *Cache) GetSearchIndex() *search.Index {
+	return c.search
+}
+
 func (c *Cache) GetPageCache() *PageCache {
 	return c.pageCache
 }
@@ -1,34 +1,326 @@
 package cache
 
 import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// PageCache stores pre-rendered HTML pages
+// Encodings lists the content encodings that entries are precompressed with, in order
+// of preference. Only gzip is in the standard library, but brotli or zstd would slot
+// in here, see compress.
+var Encodings = []string{"gzip"}
+
+// Entry is a rendered page along with the validators used for conditional requests.
+type Entry struct {
+	Body        []byte
+	ContentType string
+	// Encoded holds compressed copies of Body by content encoding, produced once when
+	// the entry is stored. Encodings that wouldn't make Body smaller are left out.
+	Encoded map[string][]byte
+	// ETag is a strong validator derived from Body alone, so that identical output
+	// gets the same ETag in every snapshot.
+	ETag string
+	// ModTime is when this exact output was first rendered, which carries over to
+	// later snapshots for as long as the output doesn't change.
+	ModTime time.Time
+}
+
+// validator is what a PageCache remembers about the entries of earlier snapshots.
+type validator struct {
+	etag    string
+	modTime time.Time
+}
+
+// flight is a render in progress, shared by every caller that missed the same path.
+type flight struct {
+	done  chan struct{}
+	entry *Entry
+	err   error
+}
+
+// Stats accumulates the counters of every PageCache sharing it, so that they survive
+// snapshots being swapped. The zero value is ready to use.
+type Stats struct {
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// PageCacheStats is a point in time view of a PageCache and its Stats.
+type PageCacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	// Entries and ResidentBytes describe the PageCache itself rather than the Stats.
+	Entries       int   `json:"entries"`
+	ResidentBytes int64 `json:"residentBytes"`
+	// MaxBytes is the budget of the PageCache, or zero when it's unbounded.
+	MaxBytes int64 `json:"maxBytes"`
+}
+
+// cached is an entry on the eviction list of a PageCache.
+type cached struct {
+	path  string
+	entry *Entry
+	size  int64
+}
+
+// PageCache stores pre-rendered HTML pages, evicting the least recently used ones once
+// they take more than its byte budget.
 type PageCache struct {
-	mu    sync.RWMutex
-	pages map[string][]byte
+	mu sync.RWMutex
+	// pages indexes the elements of lru, which holds *cached values with the most
+	// recently used first
+	pages    map[string]*list.Element
+	lru      *list.List
+	resident int64
+	maxBytes int64
+	stats    *Stats
+	// previous holds the validators of the previous snapshot, see Inherit
+	previous map[string]validator
+	// flights holds the renders in progress by path, see Render
+	flights map[string]*flight
 }
 
-func NewPageCache() *PageCache {
+// NewPageCache returns an empty PageCache holding at most `maxBytes` of rendered pages,
+// or unbounded when `maxBytes` is zero. Its counters are kept on `stats`, which may be
+// shared with other caches or be nil to get a private one.
+func NewPageCache(maxBytes int64, stats *Stats) *PageCache {
+	if stats == nil {
+		stats = &Stats{}
+	}
 	return &PageCache{
-		pages: make(map[string][]byte),
+		pages:    make(map[string]*list.Element),
+		lru:      list.New(),
+		maxBytes: maxBytes,
+		stats:    stats,
+		previous: make(map[string]validator),
+		flights:  make(map[string]*flight),
 	}
 }
 
-// Get retrieves a cached page by its route path
-func (pc *PageCache) Get(path string) ([]byte, bool) {
+// Get retrieves a cached page by its route path, counting a hit or a miss.
+func (pc *PageCache) Get(path string) (*Entry, bool) {
+	// Even reads reorder the eviction list
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	entry, ok := pc.lookupLocked(path)
+	if ok {
+		pc.stats.hits.Add(1)
+	} else {
+		pc.stats.misses.Add(1)
+	}
+	return entry, ok
+}
+
+// Peek retrieves a cached page like Get, without counting a hit or a miss. It's meant
+// for lookups that aren't traffic, e.g. warming the cache up.
+func (pc *PageCache) Peek(path string) (*Entry, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	return pc.lookupLocked(path)
+}
+
+// Stats returns the current counters of the cache.
+func (pc *PageCache) Stats() PageCacheStats {
 	pc.mu.RLock()
 	defer pc.mu.RUnlock()
 
-	page, ok := pc.pages[path]
-	return page, ok
+	return PageCacheStats{
+		Hits:          pc.stats.hits.Load(),
+		Misses:        pc.stats.misses.Load(),
+		Evictions:     pc.stats.evictions.Load(),
+		Entries:       len(pc.pages),
+		ResidentBytes: pc.resident,
+		MaxBytes:      pc.maxBytes,
+	}
+}
+
+// lookupLocked returns the entry for `path`, marking it as the most recently used.
+// Must be called with pc.mu held for writing.
+func (pc *PageCache) lookupLocked(path string) (*Entry, bool) {
+	elem, ok := pc.pages[path]
+	if !ok {
+		return nil, false
+	}
+	pc.lru.MoveToFront(elem)
+	return elem.Value.(*cached).entry, true
+}
+
+// storeLocked stores `entry` under `path`, evicting the least recently used entries
+// until the cache fits its budget again. Entries bigger than the whole budget aren't
+// stored at all. Must be called with pc.mu held for writing.
+func (pc *PageCache) storeLocked(path string, entry *Entry) {
+	if elem, ok := pc.pages[path]; ok {
+		pc.removeLocked(elem)
+	}
+
+	size := entry.size(path)
+	if pc.maxBytes > 0 && size > pc.maxBytes {
+		return
+	}
+	pc.pages[path] = pc.lru.PushFront(&cached{path: path, entry: entry, size: size})
+	pc.resident += size
+
+	for pc.maxBytes > 0 && pc.resident > pc.maxBytes {
+		pc.removeLocked(pc.lru.Back())
+		pc.stats.evictions.Add(1)
+	}
+}
+
+func (pc *PageCache) removeLocked(elem *list.Element) {
+	c := pc.lru.Remove(elem).(*cached)
+	delete(pc.pages, c.path)
+	pc.resident -= c.size
 }
 
-// Set stores a rendered page
-func (pc *PageCache) Set(path string, content []byte) {
+// size estimates how much memory the entry takes when stored under `path`.
+func (e *Entry) size(path string) int64 {
+	size := len(path) + len(e.Body) + len(e.ContentType) + len(e.ETag)
+	for encoding, body := range e.Encoded {
+		size += len(encoding) + len(body)
+	}
+	return int64(size)
+}
+
+// Set stores a rendered page, returning its entry. Output identical to what an
+// earlier snapshot rendered for the same path keeps that snapshot's ModTime.
+func (pc *PageCache) Set(path string, contentType string, body []byte) *Entry {
+	sum := sha256.Sum256(body)
+	entry := &Entry{
+		Body:        body,
+		ContentType: contentType,
+		Encoded:     compress(body),
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		ModTime:     time.Now().UTC().Truncate(time.Second),
+	}
+
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
-	pc.pages[path] = content
+	if prev, ok := pc.previous[path]; ok && prev.etag == entry.ETag {
+		entry.ModTime = prev.modTime
+	}
+	pc.storeLocked(path, entry)
+	return entry
+}
+
+// Render returns the entry cached under `path`, storing the output of `render` there
+// on a miss. Concurrent misses for the same path share a single call to `render`.
+//
+// The call runs on its own goroutine rather than on behalf of any one caller, so a
+// caller whose `ctx` is done stops waiting with ctx.Err() while the others, and the
+// cache, still get the result.
+func (pc *PageCache) Render(ctx context.Context, path string, contentType string, render func() ([]byte, error)) (*Entry, error) {
+	pc.mu.Lock()
+	if entry, ok := pc.lookupLocked(path); ok {
+		pc.mu.Unlock()
+		return entry, nil
+	}
+	f, ok := pc.flights[path]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		pc.flights[path] = f
+		go pc.fly(f, path, contentType, render)
+	}
+	pc.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.entry, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fly runs the render of `f`, storing its output before the flight is landed so that
+// later callers find either one or the other.
+func (pc *PageCache) fly(f *flight, path string, contentType string, render func() ([]byte, error)) {
+	defer func() {
+		// There's no request left to recover this panic, and it must not take the
+		// whole server down
+		if p := recover(); p != nil {
+			f.err = fmt.Errorf("panic while rendering %s: %v", path, p)
+		}
+
+		pc.mu.Lock()
+		delete(pc.flights, path)
+		pc.mu.Unlock()
+		close(f.done)
+	}()
+
+	body, err := render()
+	if err != nil {
+		f.err = err
+		return
+	}
+	f.entry = pc.Set(path, contentType, body)
+}
+
+// VariantETag returns the ETag of the copy of the entry encoded with `encoding`, which
+// must differ from the ETag of the uncompressed body.
+func (e *Entry) VariantETag(encoding string) string {
+	return e.ETag[:len(e.ETag)-1] + "-" + encoding + `"`
+}
+
+// compress produces the Encoded copies of `body`.
+func compress(body []byte) map[string][]byte {
+	encoded := make(map[string][]byte, len(Encodings))
+
+	var buf bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	gz.Write(body)
+	gz.Close()
+	if buf.Len() < len(body) {
+		encoded["gzip"] = buf.Bytes()
+	}
+	return encoded
+}
+
+// CopyFrom copies the pages of `prev` for which `keep` returns true, returning how
+// many were copied. It's meant for carrying still valid pages over to a new snapshot
+// before it starts serving requests.
+func (pc *PageCache) CopyFrom(prev *PageCache, keep func(path string) bool) int {
+	prev.mu.RLock()
+	defer prev.mu.RUnlock()
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	// Going from the least recently used keeps the order of the eviction list
+	copied := 0
+	for elem := prev.lru.Back(); elem != nil; elem = elem.Prev() {
+		c := elem.Value.(*cached)
+		if keep(c.path) {
+			pc.storeLocked(c.path, c.entry)
+			copied++
+		}
+	}
+	return copied
+}
+
+// Inherit remembers the validators of the pages `prev` currently holds, replacing
+// whatever was inherited before, so that re-rendering identical output keeps its
+// Last-Modified. Validators don't carry over any further than that, so that they stay
+// bounded by what the previous snapshot's budget allowed it to hold.
+func (pc *PageCache) Inherit(prev *PageCache) {
+	prev.mu.RLock()
+	defer prev.mu.RUnlock()
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.previous = make(map[string]validator, len(prev.pages))
+	for path, elem := range prev.pages {
+		entry := elem.Value.(*cached).entry
+		pc.previous[path] = validator{etag: entry.ETag, modTime: entry.ModTime}
+	}
 }
@@ -0,0 +1,240 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestRenderSharesConcurrentMisses checks that concurrent misses on one path make a
+// single render call, whose entry every caller gets.
+func TestRenderSharesConcurrentMisses(t *testing.T) {
+	const callers = 16
+
+	pc := NewPageCache(0, nil)
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	render := func() ([]byte, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return []byte("<p>rendered</p>"), nil
+	}
+
+	var entered, done sync.WaitGroup
+	entries := make([]*Entry, callers)
+	errs := make([]error, callers)
+	for i := range callers {
+		entered.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			entered.Done()
+			entries[i], errs[i] = pc.Render(context.Background(), "/", "text/html", render)
+		}()
+	}
+	entered.Wait()
+	<-started
+	close(release)
+	done.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("render was called %d times, want 1", n)
+	}
+	for i := range callers {
+		if errs[i] != nil {
+			t.Fatalf("caller %d got error %v", i, errs[i])
+		}
+		if entries[i] != entries[0] {
+			t.Fatalf("caller %d got a different entry", i)
+		}
+	}
+	if entry, ok := pc.Peek("/"); !ok || entry != entries[0] {
+		t.Fatal("the shared entry wasn't cached")
+	}
+}
+
+// TestRenderDoesNotCacheErrors checks that a failed render reaches every waiting
+// caller without being cached, so that the next miss renders again.
+func TestRenderDoesNotCacheErrors(t *testing.T) {
+	pc := NewPageCache(0, nil)
+	errRender := errors.New("template exploded")
+
+	var calls atomic.Int32
+	failing := func() ([]byte, error) {
+		calls.Add(1)
+		return nil, errRender
+	}
+	if _, err := pc.Render(context.Background(), "/", "text/html", failing); !errors.Is(err, errRender) {
+		t.Fatalf("got error %v, want %v", err, errRender)
+	}
+	if _, ok := pc.Peek("/"); ok {
+		t.Fatal("a failed render was cached")
+	}
+
+	working := func() ([]byte, error) {
+		calls.Add(1)
+		return []byte("<p>rendered</p>"), nil
+	}
+	entry, err := pc.Render(context.Background(), "/", "text/html", working)
+	if err != nil {
+		t.Fatalf("got error %v after a failed render", err)
+	}
+	if string(entry.Body) != "<p>rendered</p>" {
+		t.Fatalf("got body %q", entry.Body)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("render was called %d times, want 2", n)
+	}
+}
+
+// TestRenderOutlivesCancelledCaller checks that a caller giving up doesn't stop the
+// render, whose output still ends up in the cache.
+func TestRenderOutlivesCancelledCaller(t *testing.T) {
+	pc := NewPageCache(0, nil)
+	release := make(chan struct{})
+	rendered := make(chan struct{})
+	render := func() ([]byte, error) {
+		defer close(rendered)
+		<-release
+		return []byte("<p>rendered</p>"), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pc.Render(ctx, "/", "text/html", render); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+
+	close(release)
+	<-rendered
+	entry, err := pc.Render(context.Background(), "/", "text/html", func() ([]byte, error) {
+		t.Error("rendered again after the first render completed")
+		return nil, nil
+	})
+	if err != nil || string(entry.Body) != "<p>rendered</p>" {
+		t.Fatalf("got entry %v and error %v", entry, err)
+	}
+}
+
+// TestPageCacheEviction checks the byte budget of the cache: which entries it evicts
+// and in which order, and how lookups and evictions are counted.
+func TestPageCacheEviction(t *testing.T) {
+	// Every small page takes the same room, so budgets can be counted in pages. The
+	// bodies are too short for compression to pay off, which keeps Encoded empty.
+	const contentType = "text/html"
+	probe := NewPageCache(0, nil)
+	probe.Set("/a", contentType, []byte("page /a"))
+	page := probe.Stats().ResidentBytes
+
+	type op struct {
+		get  bool
+		path string
+		// body defaults to a small page, see above
+		body string
+	}
+	set := func(path string) op { return op{path: path} }
+	get := func(path string) op { return op{get: true, path: path} }
+
+	tests := []struct {
+		name string
+		// budget is in pages, zero meaning unbounded
+		budget int64
+		ops    []op
+		// want lists the cached paths, most recently used first
+		want                    []string
+		hits, misses, evictions uint64
+	}{
+		{
+			name:   "unbounded keeps everything",
+			budget: 0,
+			ops:    []op{set("/a"), set("/b"), set("/c")},
+			want:   []string{"/c", "/b", "/a"},
+		},
+		{
+			name:      "evicts the least recently stored",
+			budget:    2,
+			ops:       []op{set("/a"), set("/b"), set("/c")},
+			want:      []string{"/c", "/b"},
+			evictions: 1,
+		},
+		{
+			name:      "hits make entries recently used",
+			budget:    2,
+			ops:       []op{set("/a"), set("/b"), get("/a"), set("/c")},
+			want:      []string{"/c", "/a"},
+			hits:      1,
+			evictions: 1,
+		},
+		{
+			name:   "misses are counted",
+			budget: 2,
+			ops:    []op{set("/a"), get("/b"), get("/a"), get("/c")},
+			want:   []string{"/a"},
+			hits:   1,
+			misses: 2,
+		},
+		{
+			name:   "replacing an entry doesn't evict others",
+			budget: 2,
+			ops:    []op{set("/a"), set("/b"), set("/a")},
+			want:   []string{"/a", "/b"},
+		},
+		{
+			name:   "entries bigger than the budget aren't stored",
+			budget: 2,
+			ops:    []op{set("/a"), {path: "/b", body: strings.Repeat("x", int(3*page))}},
+			want:   []string{"/a"},
+		},
+		{
+			name:      "big entries evict as many pages as needed",
+			budget:    3,
+			ops:       []op{set("/a"), set("/b"), set("/c"), {path: "/d", body: strings.Repeat("x", int(page))}},
+			want:      []string{"/d"},
+			evictions: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := NewPageCache(tt.budget*page, nil)
+			for _, op := range tt.ops {
+				if op.get {
+					pc.Get(op.path)
+					continue
+				}
+				body := op.body
+				if body == "" {
+					body = "page " + op.path
+				}
+				pc.Set(op.path, contentType, []byte(body))
+			}
+
+			var got []string
+			for elem := pc.lru.Front(); elem != nil; elem = elem.Next() {
+				got = append(got, elem.Value.(*cached).path)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("cached %v, want %v", got, tt.want)
+			}
+
+			stats := pc.Stats()
+			if stats.Hits != tt.hits || stats.Misses != tt.misses || stats.Evictions != tt.evictions {
+				t.Errorf("got %d hits, %d misses and %d evictions, want %d, %d and %d",
+					stats.Hits, stats.Misses, stats.Evictions, tt.hits, tt.misses, tt.evictions)
+			}
+			if stats.Entries != len(tt.want) {
+				t.Errorf("got %d entries, want %d", stats.Entries, len(tt.want))
+			}
+			if tt.budget > 0 && stats.ResidentBytes > tt.budget*page {
+				t.Errorf("resident bytes %d exceed the budget of %d", stats.ResidentBytes, tt.budget*page)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/victhorio/jambe-verte/internal/content"
+)
+
+// resolved is the content that survived slug resolution, along with the redirects
+// its aliases produce.
+type resolved struct {
+	posts     []*content.Post
+	pages     []*content.Post
+	aliases   map[string]string
+	conflicts []error
+}
+
+// resolveSlugs makes sure every slug and alias points at a single post or page. Posts
+// and pages share a single namespace of slugs, which must also stay clear of the slugs
+// that `reserved` claims for other routes.
+//
+// Conflicts are resolved deterministically: the oldest post keeps its slug, then pages
+// in path order, and every later claimant is left out and reported as a conflict.
+// Drafts only get the slugs left free by everything else, so that an unpublished draft
+// can never push a live post or page out. An alias that conflicts with anything is
+// dropped on its own, keeping its post, and drafts don't claim aliases at all.
+func resolveSlugs(posts, pages []*content.Post, reserved func(slug string) bool) resolved {
+	r := resolved{aliases: make(map[string]string)}
+	owners := make(map[string]*content.Post)
+	urls := make(map[string]*content.Post)
+
+	conflict := func(post *content.Post, format string, args ...any) {
+		r.conflicts = append(r.conflicts, &content.FileError{Path: post.Path, Err: fmt.Errorf(format, args...)})
+	}
+	claim := func(post *content.Post, url string) bool {
+		if reserved != nil && reserved(post.Slug) {
+			conflict(post, "slug %q is reserved for another route", post.Slug)
+			return false
+		}
+		if owner, ok := owners[post.Slug]; ok {
+			conflict(post, "slug %q is already used by %s", post.Slug, owner.Path)
+			return false
+		}
+		owners[post.Slug] = post
+		urls[url] = post
+		return true
+	}
+
+	// Posts come sorted newest first, so claim in reverse for the oldest to win
+	kept := make(map[*content.Post]bool)
+	for _, post := range slices.Backward(posts) {
+		if !post.Draft {
+			kept[post] = claim(post, "/blog/"+post.Slug)
+		}
+	}
+	for _, page := range pages {
+		if claim(page, "/"+page.Slug) {
+			r.pages = append(r.pages, page)
+		}
+	}
+	for _, post := range slices.Backward(posts) {
+		if post.Draft {
+			kept[post] = claim(post, "/blog/"+post.Slug)
+		}
+	}
+	for _, post := range posts {
+		if kept[post] {
+			r.posts = append(r.posts, post)
+		}
+	}
+
+	// Aliases go last, so that they can never take over a live URL
+	claimAliases := func(post *content.Post, target string) {
+		for _, alias := range post.Aliases {
+			if owner, ok := urls[alias]; ok {
+				conflict(post, "alias %q is already used by %s", alias, owner.Path)
+				continue
+			}
+			if first, rest, _ := strings.Cut(strings.TrimPrefix(alias, "/"), "/"); reserved != nil && reserved(first) && (first != "blog" || rest == "") {
+				conflict(post, "alias %q is reserved for another route", alias)
+				continue
+			}
+			urls[alias] = post
+			r.aliases[alias] = target
+		}
+	}
+	for _, post := range slices.Backward(r.posts) {
+		if !post.Draft {
+			claimAliases(post, "/blog/"+post.Slug)
+		}
+	}
+	for _, page := range r.pages {
+		claimAliases(page, "/"+page.Slug)
+	}
+	return r
+}
@@ -0,0 +1,366 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// DefaultPath is where the binaries look for the site configuration when no
+// other path is given through the -config flag or the JV_CONFIG variable.
+const DefaultPath = "config.yaml"
+
+// Config is the typed site configuration shared by jv-server and jv-helper.
+//
+// Values are resolved in increasing order of precedence: built-in defaults, the
+// YAML file, JV_* environment variables and finally command line flags (which
+// are applied by each binary). Call Validate once all overrides are in place.
+type Config struct {
+	Site       SiteConfig       `yaml:"site"`
+	Server     ServerConfig     `yaml:"server"`
+	Content    ContentConfig    `yaml:"content"`
+	Cache      CacheConfig      `yaml:"cache"`
+	Feed       FeedConfig       `yaml:"feed"`
+	Pagination PaginationConfig `yaml:"pagination"`
+	Preview    PreviewConfig    `yaml:"preview"`
+	Robots     RobotsConfig     `yaml:"robots"`
+}
+
+// SiteConfig holds the metadata exposed to templates as `.Site`.
+type SiteConfig struct {
+	Title       string `yaml:"title"`
+	Author      string `yaml:"author"`
+	Description string `yaml:"description"`
+	// BaseURL is the public origin of the site (e.g. https://example.com). When
+	// empty, absolute URLs are derived from the incoming request instead, and output
+	// embedding them (feeds, sitemaps, robots.txt) isn't cached.
+	BaseURL string `yaml:"baseURL"`
+}
+
+type ServerConfig struct {
+	// Address is either a TCP address (`:8080`) or a Unix socket path prefixed by
+	// `unix:` (`unix:/run/jv/jv.sock`). It's ignored when systemd passes a socket.
+	Address string    `yaml:"address"`
+	TLS     TLSConfig `yaml:"tls"`
+	// MetricsAddress serves /metrics on a listener of its own, without authentication,
+	// e.g. on a private interface. When empty, /metrics is served on Address behind
+	// the admin token instead.
+	MetricsAddress string `yaml:"metricsAddress"`
+	// ShutdownDelay is how long /readyz fails before the server stops accepting
+	// connections on shutdown, giving load balancers time to stop sending traffic.
+	ShutdownDelay time.Duration `yaml:"shutdownDelay"`
+	// ShutdownTimeout is how long in-flight requests get to complete on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+
+	// Timeouts of the HTTP server, see http.Server. Zero means no timeout.
+	ReadTimeout  time.Duration `yaml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+	IdleTimeout  time.Duration `yaml:"idleTimeout"`
+}
+
+// TLSConfig makes the server speak HTTPS itself rather than behind a proxy. Both
+// files are PEM encoded, and are loaded again on SIGHUP so that renewed certificates
+// are picked up without a restart.
+type TLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// Enabled reports whether TLS is configured.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+type ContentConfig struct {
+	PostsDir string `yaml:"postsDir"`
+	PagesDir string `yaml:"pagesDir"`
+	// Drafts makes the server load posts marked `draft: true`. They are never listed
+	// and are only reachable through signed preview links.
+	Drafts bool `yaml:"drafts"`
+	// Workers is how many markdown files are parsed and rendered concurrently. Zero
+	// uses one worker per CPU.
+	Workers int `yaml:"workers"`
+	// Strict refuses to load content when any file is invalid, instead of skipping the
+	// broken files. Refreshes then keep serving the previous content.
+	Strict bool `yaml:"strict"`
+	// Redirects is the redirect rules file, reloaded along with the content. It's
+	// optional, and a missing file means there are no rules.
+	Redirects string `yaml:"redirects"`
+}
+
+// CacheConfig controls how rendered routes are cached.
+type CacheConfig struct {
+	// MaxBytes bounds the memory taken by rendered routes, evicting the least recently
+	// used ones past it. Zero leaves the cache unbounded.
+	MaxBytes int          `yaml:"maxBytes"`
+	WarmUp   WarmUpConfig `yaml:"warmUp"`
+}
+
+// WarmUpConfig controls rendering every known route into a new snapshot before it
+// starts serving, so that visitors never pay for a cold render after a deploy or a
+// refresh. The server only reports ready once the initial warm-up is done.
+type WarmUpConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Concurrency is how many routes are rendered at once.
+	Concurrency int `yaml:"concurrency"`
+	// Timeout bounds a whole warm-up. Routes left when it runs out are rendered on
+	// their first request instead.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type FeedConfig struct {
+	Limit int `yaml:"limit"`
+}
+
+type PaginationConfig struct {
+	// HomePosts is how many recent posts are shown on the home page.
+	HomePosts int `yaml:"homePosts"`
+	// PageSize is how many posts are shown per page on /posts and tag pages.
+	PageSize int `yaml:"pageSize"`
+}
+
+// PreviewConfig controls signed draft preview links. The signing key itself is not
+// part of the file and is read from JV_PREVIEW_SECRET instead.
+type PreviewConfig struct {
+	// DefaultTTL is how long a minted link stays valid when no ttl is requested.
+	DefaultTTL time.Duration `yaml:"defaultTTL"`
+	// MaxTTL caps the ttl that can be requested when minting a link.
+	MaxTTL time.Duration `yaml:"maxTTL"`
+}
+
+// RobotsConfig shapes the generated robots.txt, which always ends by pointing
+// crawlers at the sitemap.
+type RobotsConfig struct {
+	// Disallow lists path prefixes that all crawlers are asked to skip.
+	Disallow []string `yaml:"disallow"`
+	// Extra is appended verbatim, e.g. for groups targeting specific user agents.
+	Extra string `yaml:"extra"`
+}
+
+// Default returns the configuration used when nothing else is specified.
+func Default() *Config {
+	return &Config{
+		Site: SiteConfig{
+			Title:       "Jambe Verte",
+			Author:      "Victhor Sartório",
+			Description: "A blog by Victhor Sartório",
+		},
+		Server: ServerConfig{
+			Address:         ":8080",
+			ShutdownTimeout: 10 * time.Second,
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+		},
+		Content: ContentConfig{
+			PostsDir:  "content/posts",
+			PagesDir:  "content/pages",
+			Redirects: "content/redirects.txt",
+		},
+		Cache: CacheConfig{
+			MaxBytes: 64 << 20,
+			WarmUp: WarmUpConfig{
+				Concurrency: 4,
+				Timeout:     30 * time.Second,
+			},
+		},
+		Feed: FeedConfig{
+			Limit: 20,
+		},
+		Preview: PreviewConfig{
+			DefaultTTL: 72 * time.Hour,
+			MaxTTL:     14 * 24 * time.Hour,
+		},
+		Pagination: PaginationConfig{
+			HomePosts: 2,
+			PageSize:  10,
+		},
+		Robots: RobotsConfig{
+			Disallow: []string{"/admin/", "/preview/"},
+		},
+	}
+}
+
+// PathFromEnv returns the configuration path from JV_CONFIG, falling back to
+// DefaultPath. It's meant to be used as the default value of the -config flag.
+func PathFromEnv() string {
+	if path := os.Getenv("JV_CONFIG"); path != "" {
+		return path
+	}
+	return DefaultPath
+}
+
+// Load reads the YAML file at `path` on top of the defaults and then applies any
+// environment overrides. The result is not validated yet so that callers can still
+// apply their own flag overrides before calling Validate.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	if err := yaml.UnmarshalWithOptions(data, cfg, yaml.DisallowUnknownField()); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides configuration values with their JV_* environment variable
+// counterparts, when set.
+func (c *Config) applyEnv() error {
+	stringVars := map[string]*string{
+		"JV_SITE_TITLE":       &c.Site.Title,
+		"JV_SITE_AUTHOR":      &c.Site.Author,
+		"JV_SITE_DESCRIPTION": &c.Site.Description,
+		"JV_BASE_URL":         &c.Site.BaseURL,
+		"JV_ADDR":             &c.Server.Address,
+		"JV_METRICS_ADDR":     &c.Server.MetricsAddress,
+		"JV_TLS_CERT":         &c.Server.TLS.CertFile,
+		"JV_TLS_KEY":          &c.Server.TLS.KeyFile,
+		"JV_POSTS_DIR":        &c.Content.PostsDir,
+		"JV_PAGES_DIR":        &c.Content.PagesDir,
+		"JV_REDIRECTS":        &c.Content.Redirects,
+	}
+	for name, dst := range stringVars {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+
+	intVars := map[string]*int{
+		"JV_FEED_LIMIT":         &c.Feed.Limit,
+		"JV_PAGE_SIZE":          &c.Pagination.PageSize,
+		"JV_LOAD_WORKERS":       &c.Content.Workers,
+		"JV_WARMUP_CONCURRENCY": &c.Cache.WarmUp.Concurrency,
+		"JV_CACHE_MAX_BYTES":    &c.Cache.MaxBytes,
+	}
+	for name, dst := range intVars {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s=%q: %w", name, v, err)
+			}
+			*dst = n
+		}
+	}
+
+	boolVars := map[string]*bool{
+		"JV_DRAFTS": &c.Content.Drafts,
+		"JV_STRICT": &c.Content.Strict,
+		"JV_WARMUP": &c.Cache.WarmUp.Enabled,
+	}
+	for name, dst := range boolVars {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s=%q: %w", name, v, err)
+			}
+			*dst = b
+		}
+	}
+	return nil
+}
+
+// Validate checks that the configuration is usable, returning every problem found
+// at once. It also normalizes the base URL by stripping any trailing slash.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if strings.TrimSpace(c.Site.Title) == "" {
+		errs = append(errs, errors.New("site.title must not be empty"))
+	}
+	if c.Site.BaseURL != "" {
+		u, err := url.Parse(c.Site.BaseURL)
+		switch {
+		case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+			errs = append(errs, fmt.Errorf("site.baseURL must be an absolute http(s) URL, got %q", c.Site.BaseURL))
+		case strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "":
+			// Routes are served from the root, so the site can't live under a path
+			errs = append(errs, fmt.Errorf("site.baseURL must be an origin without a path, got %q", c.Site.BaseURL))
+		}
+		c.Site.BaseURL = strings.TrimSuffix(c.Site.BaseURL, "/")
+	}
+	if c.Server.Address == "" {
+		errs = append(errs, errors.New("server.address must not be empty"))
+	}
+	if c.Server.TLS.Enabled() && (c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls needs both certFile and keyFile"))
+	}
+	if c.Server.ShutdownDelay < 0 {
+		errs = append(errs, fmt.Errorf("server.shutdownDelay must not be negative, got %s", c.Server.ShutdownDelay))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server.shutdownTimeout must be positive, got %s", c.Server.ShutdownTimeout))
+	}
+	// A slice rather than a map, so that errors come out in a stable order
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"readTimeout", c.Server.ReadTimeout},
+		{"writeTimeout", c.Server.WriteTimeout},
+		{"idleTimeout", c.Server.IdleTimeout},
+	} {
+		if timeout.value < 0 {
+			errs = append(errs, fmt.Errorf("server.%s must not be negative, got %s", timeout.name, timeout.value))
+		}
+	}
+	if c.Content.PostsDir == "" {
+		errs = append(errs, errors.New("content.postsDir must not be empty"))
+	}
+	if c.Content.PagesDir == "" {
+		errs = append(errs, errors.New("content.pagesDir must not be empty"))
+	}
+	if c.Content.Workers < 0 {
+		errs = append(errs, fmt.Errorf("content.workers must not be negative, got %d", c.Content.Workers))
+	}
+	if c.Cache.MaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("cache.maxBytes must not be negative, got %d", c.Cache.MaxBytes))
+	}
+	if c.Cache.WarmUp.Enabled {
+		if c.Cache.WarmUp.Concurrency <= 0 {
+			errs = append(errs, fmt.Errorf("cache.warmUp.concurrency must be positive, got %d", c.Cache.WarmUp.Concurrency))
+		}
+		if c.Cache.WarmUp.Timeout <= 0 {
+			errs = append(errs, fmt.Errorf("cache.warmUp.timeout must be positive, got %s", c.Cache.WarmUp.Timeout))
+		}
+	}
+	if c.Feed.Limit <= 0 {
+		errs = append(errs, fmt.Errorf("feed.limit must be positive, got %d", c.Feed.Limit))
+	}
+	if c.Pagination.HomePosts < 0 {
+		errs = append(errs, fmt.Errorf("pagination.homePosts must not be negative, got %d", c.Pagination.HomePosts))
+	}
+	if c.Pagination.PageSize <= 0 {
+		errs = append(errs, fmt.Errorf("pagination.pageSize must be positive, got %d", c.Pagination.PageSize))
+	}
+
+	if c.Preview.DefaultTTL <= 0 {
+		errs = append(errs, fmt.Errorf("preview.defaultTTL must be positive, got %s", c.Preview.DefaultTTL))
+	}
+	if c.Preview.MaxTTL < c.Preview.DefaultTTL {
+		errs = append(errs, fmt.Errorf("preview.maxTTL (%s) must not be shorter than preview.defaultTTL (%s)", c.Preview.MaxTTL, c.Preview.DefaultTTL))
+	}
+
+	for _, path := range c.Robots.Disallow {
+		if !strings.HasPrefix(path, "/") {
+			errs = append(errs, fmt.Errorf("robots.disallow entries must start with /, got %q", path))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
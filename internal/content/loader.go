@@ -3,47 +3,168 @@ package content
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"html/template"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/goccy/go-yaml"
 	"github.com/victhorio/jambe-verte/internal/logger"
+	"github.com/victhorio/jambe-verte/internal/metrics"
 	"github.com/yuin/goldmark"
 	highlighting "github.com/yuin/goldmark-highlighting/v2"
 	meta "github.com/yuin/goldmark-meta"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+var (
+	loadDuration = metrics.NewHistogramVec(
+		"jv_content_load_duration_seconds",
+		"Time taken to load a content directory, whether it succeeded or not.",
+		metrics.DefaultBuckets, "kind",
+	)
+	loadedFiles = metrics.NewCounterVec(
+		"jv_content_files_loaded_total",
+		"Content files seen by loads, by whether they were rendered, reused from the previous load or failed.",
+		"kind", "result",
+	)
 )
 
 var (
 	// postFilenameRegex validates post filenames follow the YYYY-MM-DD-slug.md pattern
 	postFilenameRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-[a-z0-9-]+\.md$`)
 
+	// slugRegex validates slugs set through the `slug` frontmatter
+	slugRegex = regexp.MustCompile(`^[a-z0-9-]+$`)
+
 	// mdParser is the shared goldmark instance for converting markdown to HTML
 	mdParser = goldmark.New(
 		goldmark.WithExtensions(
 			meta.Meta,
 			highlighting.NewHighlighting(highlighting.WithStyle("github")),
 		),
+		goldmark.WithParserOptions(
+			// IDs themselves come from headingIDs, installed per document in loadPost
+			parser.WithAutoHeadingID(),
+		),
 	)
+
+	// minTOCEntries is the fewest headings worth rendering a table of contents for
+	minTOCEntries = 2
 )
 
+// LoadOptions tweaks how LoadContent treats the files it reads.
+type LoadOptions struct {
+	// IncludeDrafts keeps `draft: true` content (flagged with Post.Draft) instead of
+	// skipping it.
+	IncludeDrafts bool
+	// Workers is how many files are parsed and rendered concurrently. Zero or less
+	// uses one worker per CPU.
+	Workers int
+	// Strict fails the whole load when any file is invalid, instead of skipping it
+	// with a warning. It also rejects unknown frontmatter keys and empty titles.
+	Strict bool
+}
+
+// FileRecord remembers a content file as it was the last time it was loaded, so that
+// reloads can reuse its parsed Post instead of rendering it again.
+type FileRecord struct {
+	// Hash is the hex encoded SHA-256 of the file contents.
+	Hash    string
+	ModTime time.Time
+	Size    int64
+	// Post is the parsed file, or nil if it was skipped (e.g. a draft while drafts are
+	// excluded) or failed to load, in which case Err is set.
+	Post *Post
+	Err  error
+}
+
+// Files maps content file paths to their records.
+type Files map[string]FileRecord
+
+// Changes lists the files that differ from a previous load, by path.
+type Changes struct {
+	Added   []string `json:"added"`
+	Changed []string `json:"changed"`
+	Removed []string `json:"removed"`
+}
+
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Changed) == 0 && len(c.Removed) == 0
+}
+
+// Merge returns the union of `c` and `other`, e.g. to report posts and pages together.
+// The lists of the result are never nil, so they encode as empty JSON arrays.
+func (c Changes) Merge(other Changes) Changes {
+	return Changes{
+		Added:   append(append([]string{}, c.Added...), other.Added...),
+		Changed: append(append([]string{}, c.Changed...), other.Changed...),
+		Removed: append(append([]string{}, c.Removed...), other.Removed...),
+	}
+}
+
+// Loaded is the content of a directory along with the records of the files it was
+// loaded from.
+type Loaded struct {
+	Posts []*Post
+	Files Files
+}
+
+// Err joins the errors of every file that failed to load, in path order, or returns
+// nil if all of them loaded.
+func (l Loaded) Err() error {
+	paths := slices.Sorted(maps.Keys(l.Files))
+	var errs []error
+	for _, path := range paths {
+		if err := l.Files[path].Err; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // LoadContent reads all the files ending in Markdown in a given `dir`, returning a list
 // of Post structs. If the `isPost` parameter is true, the naming convention for posts
 // will be checked against the YYYY-MM-DD-slug.md pattern and results will be returned
 // sorted from newest to oldest.
-func LoadContent(dir string, isPost bool) ([]*Post, error) {
+func LoadContent(dir string, isPost bool, opts LoadOptions) ([]*Post, error) {
+	loaded, _, err := Reload(dir, isPost, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	return loaded.Posts, nil
+}
+
+// Reload works like LoadContent, but reuses the posts of `previous` (which may be nil)
+// for files whose contents haven't changed, reporting which files did. Files whose
+// size and modification time are unchanged aren't even read. The same *Post is
+// returned for unchanged files, so callers can compare posts by identity.
+func Reload(dir string, isPost bool, opts LoadOptions, previous Files) (Loaded, Changes, error) {
 	ctx := context.Background()
 	start := time.Now()
 
+	kind := "pages"
+	if isPost {
+		kind = "posts"
+	}
+	defer func() {
+		loadDuration.Observe(time.Since(start).Seconds(), kind)
+	}()
+
 	paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
 	if err != nil {
-		return nil, err
+		return Loaded{}, Changes{}, err
 	}
 
 	// Since I pretty much always expect to have content on hand when calling this function
@@ -51,100 +172,310 @@ func LoadContent(dir string, isPost bool) ([]*Post, error) {
 	// being more general/friendly.
 	if len(paths) == 0 {
 		logger.Logger.ErrorContext(ctx, "No content found", "directory", dir)
-		return nil, fmt.Errorf("LoadContent: no content found in %s", dir)
+		return Loaded{}, Changes{}, fmt.Errorf("LoadContent: no content found in %s", dir)
 	}
 
+	// Parse and render across a bounded pool of workers. Each result lands at its
+	// path's index, so the outcome doesn't depend on scheduling.
+	type result struct {
+		record    FileRecord
+		unchanged bool
+	}
+	results := make([]result, len(paths))
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(paths))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				prev, existed := previous[paths[i]]
+				record, unchanged := loadRecord(paths[i], isPost, opts, prev, existed)
+				results[i] = result{record: record, unchanged: unchanged}
+			}
+		}()
+	}
+	for i := range paths {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	files := make(Files, len(paths))
+	var changes Changes
 	var contentList []*Post
-	for _, path := range paths {
-		post, err := loadPost(path, isPost)
-		if err != nil {
-			logger.Logger.WarnContext(
-				ctx,
-				"Failed to load post",
-				"path", path,
-				"error", err,
-			)
+	reused, rendered, failed := 0, 0, 0
+	for i, path := range paths {
+		record := results[i].record
+		files[path] = record
+
+		_, existed := previous[path]
+		switch {
+		case !existed:
+			changes.Added = append(changes.Added, path)
+		case results[i].unchanged:
+			reused++
+		default:
+			changes.Changed = append(changes.Changed, path)
+		}
+
+		if record.Err != nil {
+			failed++
 			continue
 		}
+		if !results[i].unchanged {
+			rendered++
+		}
 
 		// Some content will return as nil indicating that we should skip it even though
 		// there weren't any errors.
-		if post != nil {
-			contentList = append(contentList, post)
+		if record.Post != nil {
+			contentList = append(contentList, record.Post)
 		}
 	}
+	for path := range previous {
+		if _, ok := files[path]; !ok {
+			changes.Removed = append(changes.Removed, path)
+		}
+	}
+	slices.Sort(changes.Removed)
+
+	loadedFiles.Add(float64(rendered), kind, "rendered")
+	loadedFiles.Add(float64(len(paths)-rendered-failed), kind, "reused")
+	loadedFiles.Add(float64(failed), kind, "failed")
+
+	loaded := Loaded{Posts: contentList, Files: files}
+	if failed > 0 && opts.Strict {
+		logger.Logger.ErrorContext(ctx, "Content failed validation", "directory", dir, "failed", failed)
+		return Loaded{}, changes, fmt.Errorf("%d invalid file(s) in %s: %w", failed, dir, loaded.Err())
+	}
+	if failed > 0 {
+		// Report every broken file at once, so they can all be fixed in one go
+		logger.Logger.WarnContext(
+			ctx,
+			"Failed to load some files, skipping them",
+			"directory", dir,
+			"failed", failed,
+			"error", loaded.Err(),
+		)
+	}
 
 	if isPost {
-		slices.SortFunc(contentList, func(a, b *Post) int {
-			// Note that we do b.Date.Compare instead of a.Date.Compare since SortFunc will
-			// sort in ascending order, but we want the highest dates (most recent) first.
-			return b.Date.Compare(a.Date)
-		})
+		slices.SortFunc(contentList, comparePosts)
 	}
 
 	duration := time.Since(start)
-	logger.Logger.InfoContext(ctx, "Loaded content", "is_post", isPost, "count", len(contentList), "directory", dir, "duration", duration.String())
-	return contentList, nil
+	logger.Logger.InfoContext(
+		ctx,
+		"Loaded content",
+		"is_post", isPost,
+		"count", len(contentList),
+		"reused", reused,
+		"directory", dir,
+		"workers", workers,
+		"duration", duration.String(),
+	)
+	return loaded, changes, nil
 }
 
-// loadPost is a helper function that loads a post from a given path and returns a Post struct.
-// If it's reading an actual isPost, it will assert the naming convention of YYYY-MM-DD-slug.md
-// as well as clean up the date prefix when creating returning the slug.
-func loadPost(path string, isPost bool) (*Post, error) {
+// comparePosts orders posts from newest to oldest. Posts sharing a date are ordered by
+// their publishing time and then by slug, so that the order never depends on the order
+// files happened to be loaded in.
+func comparePosts(a, b *Post) int {
+	// Note that we do b.Date.Compare instead of a.Date.Compare since SortFunc will
+	// sort in ascending order, but we want the highest dates (most recent) first.
+	if c := b.Date.Compare(a.Date); c != 0 {
+		return c
+	}
+	if c := b.PublishAt.Compare(a.PublishAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Slug, b.Slug)
+}
+
+// loadRecord loads the file at `path`, reusing `prev` when the file's contents are the
+// same. It reports whether they were.
+func loadRecord(path string, isPost bool, opts LoadOptions, prev FileRecord, hasPrev bool) (FileRecord, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileRecord{Err: &FileError{Path: path, Err: fmt.Errorf("failed to stat: %w", err)}}, false
+	}
+	if hasPrev && prev.Hash != "" && info.Size() == prev.Size && info.ModTime().Equal(prev.ModTime) {
+		return prev, true
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return FileRecord{Err: &FileError{Path: path, Err: fmt.Errorf("failed to read: %w", err)}}, false
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	// Touched but otherwise unchanged files keep their parsed post as is
+	if hasPrev && prev.Hash == hash {
+		prev.ModTime = info.ModTime()
+		prev.Size = info.Size()
+		return prev, true
+	}
+
+	post, err := loadPost(path, content, info.ModTime(), isPost, opts)
+	return FileRecord{
+		Hash:    hash,
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		Post:    post,
+		Err:     err,
+	}, false
+}
+
+// loadPost is a helper function that parses the `content` read from `path` and returns a
+// Post struct. If it's reading an actual isPost, it will assert the naming convention of
+// YYYY-MM-DD-slug.md as well as clean up the date prefix when creating returning the slug.
+func loadPost(path string, content []byte, modTime time.Time, isPost bool, opts LoadOptions) (*Post, error) {
 	// First, if it's a post, let's make sure that the file follows the correct naming convention of YYYY-MM-DD-slug.md
 	base := filepath.Base(path)
 	if isPost {
 		if !postFilenameRegex.MatchString(base) {
-			return nil, fmt.Errorf("invalid filename for post `%s`: expected: YYYY-MM-DD-slug.md", path)
+			return nil, &FileError{Path: path, Err: errors.New("invalid filename for post: expected YYYY-MM-DD-slug.md")}
 		}
 	}
 
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read post `%s`: %w", path, err)
-	}
-
+	// Parse and render as separate steps so we can collect headings from the AST in between
 	var htmlBuf bytes.Buffer
-	context := parser.NewContext()
-	if err := mdParser.Convert(content, &htmlBuf, parser.WithContext(context)); err != nil {
-		return nil, fmt.Errorf("failed to convert post `%s`: %w", path, err)
+	context := parser.NewContext(parser.WithIDs(newHeadingIDs()))
+	doc := mdParser.Parser().Parse(text.NewReader(content), parser.WithContext(context))
+	if err := mdParser.Renderer().Render(&htmlBuf, content, doc); err != nil {
+		return nil, &FileError{Path: path, Err: fmt.Errorf("failed to convert: %w", err)}
 	}
 
-	// Get metadata
+	// Get metadata. goldmark-meta already strips it from the document, but we decode it
+	// ourselves so that errors point at the right line.
 	var postMeta PostFrontmatter
-	metaData := meta.Get(context)
-	yamlBytes, err := yaml.Marshal(metaData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal post `%s` metadata: %w", path, err)
+	front, ok := splitFrontmatter(content)
+	if !ok {
+		return nil, &FileError{Path: path, Line: 1, Err: errors.New("missing frontmatter")}
 	}
-	if err := yaml.Unmarshal(yamlBytes, &postMeta); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal post `%s` metadata: %w", path, err)
+	if line, err := front.decode(&postMeta, opts.Strict); err != nil {
+		return nil, &FileError{Path: path, Line: line, Err: fmt.Errorf("invalid frontmatter: %w", err)}
 	}
 
-	// Skip drafts
-	// TODO: Add a flag to the command line to include drafts
-	if postMeta.Draft {
+	// Skip drafts unless they were explicitly requested
+	if postMeta.Draft && !opts.IncludeDrafts {
 		return nil, nil
 	}
 
+	if opts.Strict && strings.TrimSpace(postMeta.Title) == "" {
+		return nil, &FileError{Path: path, Line: front.line("title"), Err: errors.New("title must not be empty")}
+	}
+
 	// Parse date
+	if postMeta.Date == "" {
+		return nil, &FileError{Path: path, Line: front.line("date"), Err: errors.New("missing date")}
+	}
 	date, err := time.Parse("2006-01-02", postMeta.Date)
 	if err != nil {
-		return nil, fmt.Errorf("invalid date format for post `%s`: %w", path, err)
+		return nil, &FileError{Path: path, Line: front.line("date"), Err: fmt.Errorf("invalid date %q: expected YYYY-MM-DD", postMeta.Date)}
+	}
+
+	// Parse the optional publishing timestamp, which defaults to the post date
+	publishAt := date
+	if postMeta.PublishAt != "" {
+		publishAt, err = time.Parse(time.RFC3339, postMeta.PublishAt)
+		if err != nil {
+			return nil, &FileError{Path: path, Line: front.line("publishAt"), Err: fmt.Errorf("invalid publishAt %q: expected RFC 3339", postMeta.PublishAt)}
+		}
+	}
+
+	// Parse the optional update timestamp, accepting either a plain date or RFC 3339
+	updated := publishAt
+	if postMeta.Updated != "" {
+		updated, err = parseTimestamp(postMeta.Updated)
+		if err != nil {
+			return nil, &FileError{Path: path, Line: front.line("updated"), Err: fmt.Errorf("invalid updated: %w", err)}
+		}
+	}
+
+	// Build the table of contents unless the post opted out with `toc: false`
+	var toc []*TOCEntry
+	if postMeta.TOC == nil || *postMeta.TOC {
+		toc = buildTOC(doc, content)
+		if countTOCEntries(toc) < minTOCEntries {
+			toc = nil
+		}
 	}
 
-	// Generate slug from filename
+	// Generate slug from filename, unless it's set explicitly
 	slug := strings.TrimSuffix(base, filepath.Ext(base))
 	if isPost {
 		slug = slug[11:] // Remove the date prefix, which we already asserted is present
 	}
+	if postMeta.Slug != "" {
+		if !slugRegex.MatchString(postMeta.Slug) {
+			return nil, &FileError{Path: path, Line: front.line("slug"), Err: fmt.Errorf("invalid slug %q: only lowercase letters, digits and hyphens are allowed", postMeta.Slug)}
+		}
+		slug = postMeta.Slug
+	}
+
+	aliases, err := resolveAliases(postMeta.Aliases, isPost)
+	if err != nil {
+		return nil, &FileError{Path: path, Line: front.line("aliases"), Err: err}
+	}
 
 	return &Post{
+		Path:        path,
 		Slug:        slug,
 		Title:       postMeta.Title,
 		Date:        date,
 		Tags:        postMeta.Tags,
 		Description: postMeta.Description,
 		HTML:        template.HTML(htmlBuf.String()),
+		Draft:       postMeta.Draft,
+		PublishAt:   publishAt,
+		Updated:     updated,
+		ModTime:     modTime,
+		TOC:         toc,
+		Aliases:     aliases,
 	}, nil
 }
+
+// resolveAliases turns the `aliases` frontmatter into URL paths. Bare slugs are taken
+// relative to where the content lives, so `old-name` on a post means /blog/old-name.
+func resolveAliases(aliases []string, isPost bool) ([]string, error) {
+	prefix := "/"
+	if isPost {
+		prefix = "/blog/"
+	}
+
+	var paths []string
+	for _, alias := range aliases {
+		if !strings.HasPrefix(alias, "/") {
+			if !slugRegex.MatchString(alias) {
+				return nil, fmt.Errorf("invalid alias %q: expected a slug or a path starting with /", alias)
+			}
+			alias = prefix + alias
+		}
+		if strings.ContainsAny(alias, "?# ") || alias != path.Clean(alias) || alias == "/" {
+			return nil, fmt.Errorf("invalid alias %q: expected a clean path without a query or fragment", alias)
+		}
+		paths = append(paths, alias)
+	}
+	return paths, nil
+}
+
+// parseTimestamp parses frontmatter timestamps given either as YYYY-MM-DD or RFC 3339.
+func parseTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC 3339, got %q", value)
+	}
+	return t, nil
+}
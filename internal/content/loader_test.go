@@ -0,0 +1,86 @@
+package content
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/victhorio/jambe-verte/internal/logger"
+)
+
+// BenchmarkLoadContent loads a synthetic corpus with different worker counts, checking
+// along the way that every worker count loads posts in the same order. Run it with:
+//
+//	go test ./internal/content -run '^$' -bench LoadContent
+func BenchmarkLoadContent(b *testing.B) {
+	dir := b.TempDir()
+	if err := writeSyntheticCorpus(dir, 1000); err != nil {
+		b.Fatal(err)
+	}
+
+	// Loading logs a line per run, which would drown the results
+	defaultLogger := logger.Logger
+	logger.Logger = slog.New(slog.DiscardHandler)
+	b.Cleanup(func() { logger.Logger = defaultLogger })
+
+	var order []string
+	for _, workers := range slices.Compact([]int{1, runtime.GOMAXPROCS(0)}) {
+		opts := LoadOptions{Workers: workers}
+
+		posts, err := LoadContent(dir, true, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		slugs := make([]string, len(posts))
+		for i, post := range posts {
+			slugs[i] = post.Slug
+		}
+		if order == nil {
+			order = slugs
+		} else if !slices.Equal(order, slugs) {
+			b.Fatalf("posts loaded with %d workers are in a different order", workers)
+		}
+
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := LoadContent(dir, true, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// writeSyntheticCorpus writes `n` posts to `dir` with a mix of headings, prose, lists
+// and highlighted code. Several posts share each date so that tie-breaking is exercised.
+func writeSyntheticCorpus(dir string, n int) error {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range n {
+		date := start.AddDate(0, 0, i/3).Format("2006-01-02")
+		slug := fmt.Sprintf("synthetic-post-%04d", i)
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "---\ntitle: \"Synthetic post %d\"\ndate: \"%s\"\ntags: [bench, tag-%d]\ndescription: \"Post number %d.\"\n---\n\n", i, date, i%7, i)
+		for section := range 5 {
+			fmt.Fprintf(&b, "## Section %d\n\n", section)
+			for range 3 {
+				b.WriteString("Lorem ipsum dolor sit amet, *consectetur* adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud `exercitation` ullamco laboris nisi ut aliquip ex ea commodo consequat.\n\n")
+			}
+			b.WriteString("- first item\n- second item with a [link](https://example.com)\n- third item\n\n")
+			b.WriteString("```go\nfunc main() {\n\tfor i := range 10 {\n\t\tfmt.Println(\"hello\", i)\n\t}\n}\n```\n\n")
+		}
+
+		path := filepath.Join(dir, date+"-"+slug+".md")
+		if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", path, err)
+		}
+	}
+	return nil
+}
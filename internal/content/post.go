@@ -6,12 +6,29 @@ import (
 )
 
 type Post struct {
+	// Path is the source file the post was loaded from.
+	Path        string
 	Slug        string
 	Title       string
 	Date        time.Time
 	Tags        []string
 	Description string
 	HTML        template.HTML
+	// Draft is only ever true when drafts were explicitly requested at load time.
+	Draft bool
+	// PublishAt is when the post becomes visible. It comes from the `publishAt`
+	// frontmatter when present and otherwise defaults to the start of Date (UTC).
+	PublishAt time.Time
+	// Updated is when the post last changed in a meaningful way. It comes from the
+	// `updated` frontmatter when present and otherwise defaults to PublishAt.
+	Updated time.Time
+	// ModTime is the modification time of the source file.
+	ModTime time.Time
+	// TOC is the nested table of contents. It's empty when the post has too few
+	// headings or opted out with `toc: false`.
+	TOC []*TOCEntry
+	// Aliases are old URL paths that redirect here, e.g. from before a rename.
+	Aliases []string
 }
 
 type PostFrontmatter struct {
@@ -20,4 +37,13 @@ type PostFrontmatter struct {
 	Tags        []string `yaml:"tags"`
 	Description string   `yaml:"description"`
 	Draft       bool     `yaml:"draft"`
+	PublishAt   string   `yaml:"publishAt"`
+	Updated     string   `yaml:"updated"`
+	// TOC is a pointer so that an absent key can default to true
+	TOC *bool `yaml:"toc"`
+	// Slug overrides the slug derived from the filename.
+	Slug string `yaml:"slug"`
+	// Aliases lists old URLs of the post. Bare slugs are relative to where the content
+	// lives (/blog/ for posts, / for pages), while anything starting with / is a path.
+	Aliases []string `yaml:"aliases"`
 }
@@ -0,0 +1,137 @@
+package content
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/victhorio/jambe-verte/internal/fold"
+	"github.com/yuin/goldmark/ast"
+)
+
+// TOCEntry is a heading in a post's table of contents. Entries nest by heading level,
+// so an h3 following an h2 ends up in the h2's Children.
+type TOCEntry struct {
+	ID       string
+	Title    string
+	Level    int
+	Children []*TOCEntry
+}
+
+// headingIDs generates heading anchors for goldmark. Unlike goldmark's default, which
+// drops every non-ASCII byte, accented letters are folded to ASCII (so "Informação"
+// becomes "informacao") and letters from other scripts are kept as they are. IDs only
+// depend on the heading text and order, so they're stable across renders.
+type headingIDs struct {
+	used map[string]bool
+}
+
+func newHeadingIDs() *headingIDs {
+	return &headingIDs{used: make(map[string]bool)}
+}
+
+func (s *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	base := slugify(string(value))
+	if base == "" {
+		base = "section"
+	}
+
+	// Repeated headings get a numeric suffix: intro, intro-1, intro-2...
+	id := base
+	for i := 1; s.used[id]; i++ {
+		id = base + "-" + strconv.Itoa(i)
+	}
+	s.used[id] = true
+	return []byte(id)
+}
+
+func (s *headingIDs) Put(value []byte) {
+	s.used[string(value)] = true
+}
+
+// slugify turns heading text into an anchor: folded, lowercase, with runs of anything
+// other than letters and digits collapsed into single hyphens.
+func slugify(text string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range fold.String(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+		} else {
+			pendingHyphen = true
+		}
+	}
+	return b.String()
+}
+
+// buildTOC collects the headings of a parsed document into a nested table of contents.
+func buildTOC(doc ast.Node, source []byte) []*TOCEntry {
+	var roots []*TOCEntry
+	var stack []*TOCEntry
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		id, _ := heading.AttributeString("id")
+		idBytes, _ := id.([]byte)
+		entry := &TOCEntry{
+			ID:    string(idBytes),
+			Title: nodeText(heading, source),
+			Level: heading.Level,
+		}
+
+		// Pop until the top of the stack is a shallower heading we can nest under
+		for len(stack) > 0 && stack[len(stack)-1].Level >= entry.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		stack = append(stack, entry)
+
+		return ast.WalkSkipChildren, nil
+	})
+	return roots
+}
+
+// countTOCEntries returns the total number of entries in `toc`, at every depth.
+func countTOCEntries(toc []*TOCEntry) int {
+	n := len(toc)
+	for _, entry := range toc {
+		n += countTOCEntries(entry.Children)
+	}
+	return n
+}
+
+// nodeText concatenates the text segments under `n`, e.g. the plain text of a heading
+// that contains emphasis or inline code.
+func nodeText(n ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := child.(type) {
+		case *ast.Text:
+			buf.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return buf.String()
+}
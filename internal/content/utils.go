@@ -1,10 +1,24 @@
 package content
 
 import (
+	"compress/gzip"
 	"context"
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 
 	"github.com/victhorio/jambe-verte/internal/logger"
+	"github.com/victhorio/jambe-verte/internal/metrics"
+)
+
+// cssOutput is where `bun run build-css` writes the stylesheet, see package.json.
+const cssOutput = "static/css/output.css"
+
+var cssRebuilds = metrics.NewCounterVec(
+	"jv_css_rebuilds_total",
+	"CSS rebuilds by outcome: success, failure, or skipped when bun isn't installed.",
+	"result",
 )
 
 func RebuildCSS(ctx context.Context) {
@@ -13,6 +27,7 @@ func RebuildCSS(ctx context.Context) {
 	// Check if bun exists
 	if _, err := exec.LookPath("bun"); err != nil {
 		logger.Logger.WarnContext(ctx, "bun not found in PATH, skipping CSS rebuild")
+		cssRebuilds.Inc("skipped")
 		return
 	}
 
@@ -20,7 +35,44 @@ func RebuildCSS(ctx context.Context) {
 	cmd := exec.Command("bun", "run", "build-css")
 	if err := cmd.Run(); err != nil {
 		logger.Logger.WarnContext(ctx, "CSS rebuild failed", "error", err)
-	} else {
-		logger.Logger.InfoContext(ctx, "CSS rebuild completed successfully")
+		cssRebuilds.Inc("failure")
+		return
+	}
+
+	// Precompress the stylesheet so that it's served without compressing it on every
+	// request. A failure here only costs bandwidth, since a stale sidecar is ignored.
+	if err := writeGzipSidecar(cssOutput); err != nil {
+		logger.Logger.WarnContext(ctx, "Failed to precompress CSS", "error", err)
+	}
+	logger.Logger.InfoContext(ctx, "CSS rebuild completed successfully")
+	cssRebuilds.Inc("success")
+}
+
+// writeGzipSidecar writes a gzipped copy of the file at `path` to `path`.gz, going
+// through a temporary file so that it's never served half written.
+func writeGzipSidecar(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".jv-sidecar-*")
+	if err != nil {
+		return fmt.Errorf("failed to create sidecar for %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	gz, _ := gzip.NewWriterLevel(tmp, gzip.BestCompression)
+	if _, err := gz.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write sidecar for %s: %w", path, err)
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write sidecar for %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write sidecar for %s: %w", path, err)
 	}
+	return os.Rename(tmp.Name(), path+".gz")
 }
@@ -0,0 +1,112 @@
+package content
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/goccy/go-yaml"
+)
+
+// FileError is a problem with a single content file, pointing at the offending line
+// when there is one.
+type FileError struct {
+	Path string
+	// Line is 1-based, or 0 when the problem isn't tied to a line (e.g. a bad filename).
+	Line int
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %v", e.Path, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// FileErrors collects every FileError in the tree of `err`, such as the joined errors
+// returned by strict loads, in order.
+func FileErrors(err error) []*FileError {
+	switch e := err.(type) {
+	case *FileError:
+		return []*FileError{e}
+	case interface{ Unwrap() []error }:
+		var all []*FileError
+		for _, inner := range e.Unwrap() {
+			all = append(all, FileErrors(inner)...)
+		}
+		return all
+	case interface{ Unwrap() error }:
+		return FileErrors(e.Unwrap())
+	}
+	return nil
+}
+
+// frontmatter is the YAML block at the top of a markdown file, delimited by lines of
+// dashes. It mirrors what goldmark-meta strips from the rendered document, but is
+// decoded separately so that errors can point at the right line of the file.
+type frontmatter struct {
+	source []byte
+	// firstLine is the line of the file where `source` starts.
+	firstLine int
+}
+
+// splitFrontmatter extracts the frontmatter of `content`, if it has any.
+func splitFrontmatter(content []byte) (frontmatter, bool) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines) == 0 || !isSeparator(lines[0]) {
+		return frontmatter{}, false
+	}
+
+	var source []byte
+	for _, line := range lines[1:] {
+		if isSeparator(line) {
+			return frontmatter{source: source, firstLine: 2}, true
+		}
+		source = append(source, line...)
+	}
+	return frontmatter{}, false
+}
+
+func isSeparator(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	return len(line) >= 3 && len(bytes.Trim(line, "-")) == 0
+}
+
+// decode unmarshals the frontmatter into `v`. Unknown keys are rejected when `strict`.
+func (f frontmatter) decode(v any, strict bool) (int, error) {
+	var opts []yaml.DecodeOption
+	if strict {
+		opts = append(opts, yaml.DisallowUnknownField())
+	}
+	err := yaml.UnmarshalWithOptions(f.source, v, opts...)
+	if err == nil {
+		return 0, nil
+	}
+
+	var yamlErr yaml.Error
+	if errors.As(err, &yamlErr) {
+		line := f.firstLine
+		if tok := yamlErr.GetToken(); tok != nil && tok.Position != nil {
+			line += tok.Position.Line - 1
+		}
+		return line, errors.New(yamlErr.GetMessage())
+	}
+	return f.firstLine, err
+}
+
+// line returns the line of the file where `key` is set. Missing keys point at the
+// opening separator instead.
+func (f frontmatter) line(key string) int {
+	prefix := []byte(key + ":")
+	for i, line := range bytes.SplitAfter(f.source, []byte("\n")) {
+		if bytes.HasPrefix(line, prefix) {
+			return f.firstLine + i
+		}
+	}
+	return f.firstLine - 1
+}
@@ -0,0 +1,113 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/victhorio/jambe-verte/internal/logger"
+)
+
+// Routes renders each of `routes` by issuing an in-process GET against `handler` and
+// writes the responses under `outDir`. Going through the real handlers guarantees the
+// exported files are byte-identical to what the server would send.
+//
+// Routes without an extension are written with pretty URLs (`/posts` becomes
+// `posts/index.html`), while routes like `/feed.xml` are written as-is.
+func Routes(ctx context.Context, handler http.Handler, baseURL string, routes []string, outDir string) error {
+	start := time.Now()
+
+	for _, route := range routes {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+route, nil)
+		if err != nil {
+			return fmt.Errorf("rendering %s: %w", route, err)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			return fmt.Errorf("rendering %s: unexpected status %d", route, rec.Code)
+		}
+
+		file, err := routeFile(route)
+		if err != nil {
+			return fmt.Errorf("rendering %s: %w", route, err)
+		}
+		dst := filepath.Join(outDir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("creating directory for %s: %w", route, err)
+		}
+		if err := os.WriteFile(dst, rec.Body.Bytes(), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", dst, err)
+		}
+	}
+
+	logger.Logger.InfoContext(ctx, "Exported routes", "count", len(routes), "out", outDir, "duration", time.Since(start).String())
+	return nil
+}
+
+// routeFile maps a route to the file that should hold it, relative to the output root.
+// Routes are escaped URL paths, while static hosts look files up by the unescaped path.
+func routeFile(route string) (string, error) {
+	route, err := url.PathUnescape(strings.TrimPrefix(route, "/"))
+	if err != nil {
+		return "", err
+	}
+	if route == "" {
+		return "index.html", nil
+	}
+	if path.Ext(route) != "" {
+		return route, nil
+	}
+	return path.Join(route, "index.html"), nil
+}
+
+// CopyDir recursively copies the regular files under `src` into `dst`.
+func CopyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return copyFile(p, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,98 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   *atomAuthor `xml:"author,omitempty"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    atomText       `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// Atom encodes the feed as Atom 1.0 (RFC 4287), advertising `selfURL` as its self link.
+func Atom(f *Feed, selfURL string) ([]byte, error) {
+	entries := make([]atomEntry, len(f.Entries))
+	for i, e := range f.Entries {
+		entry := atomEntry{
+			Title:     e.Title,
+			ID:        e.ID,
+			Links:     []atomLink{{Href: e.URL, Rel: "alternate", Type: "text/html"}},
+			Published: e.Published.Format(time.RFC3339),
+			Updated:   e.Updated.Format(time.RFC3339),
+			Content:   atomText{Type: "html", Body: e.ContentHTML},
+		}
+		if e.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Body: e.Summary}
+		}
+		for _, tag := range e.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		entries[i] = entry
+	}
+
+	// Atom requires a feed-level updated timestamp, even for an empty feed
+	updated := f.Updated
+	if updated.IsZero() {
+		updated = time.Unix(0, 0).UTC()
+	}
+
+	doc := atomFeed{
+		Title:    f.Title,
+		Subtitle: f.Description,
+		ID:       f.SiteURL,
+		Updated:  updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.SiteURL, Rel: "alternate", Type: "text/html"},
+			{Href: selfURL, Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: entries,
+	}
+	if f.Author != "" {
+		doc.Author = &atomAuthor{Name: f.Author}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,93 @@
+package feed
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/victhorio/jambe-verte/internal/content"
+)
+
+// Feed is the format-agnostic model that the RSS, Atom and JSON Feed encoders
+// render from. All URLs in it are absolute.
+type Feed struct {
+	Title       string
+	Description string
+	Author      string
+	// SiteURL is the HTML page that the feed mirrors (e.g. the home page).
+	SiteURL string
+	// Updated is the most recent Updated timestamp among the entries.
+	Updated time.Time
+	Entries []Entry
+}
+
+type Entry struct {
+	// ID is a stable, unique identifier. We use the post URL.
+	ID          string
+	URL         string
+	Title       string
+	Summary     string
+	ContentHTML string
+	Tags        []string
+	Published   time.Time
+	Updated     time.Time
+}
+
+// New builds a feed for `posts`, which are expected to already be sorted and trimmed
+// to the desired length. `baseURL` is the site origin without a trailing slash.
+func New(title, description, author, baseURL string, posts []*content.Post) *Feed {
+	f := &Feed{
+		Title:       title,
+		Description: description,
+		Author:      author,
+		SiteURL:     baseURL + "/",
+		Entries:     make([]Entry, len(posts)),
+	}
+
+	for i, post := range posts {
+		postURL := baseURL + "/blog/" + post.Slug
+		f.Entries[i] = Entry{
+			ID:          postURL,
+			URL:         postURL,
+			Title:       post.Title,
+			Summary:     post.Description,
+			ContentHTML: AbsolutizeLinks(string(post.HTML), postURL),
+			Tags:        post.Tags,
+			Published:   post.PublishAt,
+			Updated:     post.Updated,
+		}
+		if post.Updated.After(f.Updated) {
+			f.Updated = post.Updated
+		}
+	}
+	return f
+}
+
+// linkAttrRegex matches href and src attributes as emitted by goldmark (always
+// double quoted), capturing the attribute prefix and the raw URL.
+var linkAttrRegex = regexp.MustCompile(`(\s(?:href|src)=")([^"]*)"`)
+
+// AbsolutizeLinks rewrites relative href and src attributes in `html` so that they
+// resolve against `pageURL`. Feed readers render content outside of our site, so
+// links like `/about` or `#section` would otherwise point nowhere.
+func AbsolutizeLinks(html string, pageURL string) string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return html
+	}
+
+	return linkAttrRegex.ReplaceAllStringFunc(html, func(match string) string {
+		parts := linkAttrRegex.FindStringSubmatch(match)
+		prefix, raw := parts[1], parts[2]
+
+		// goldmark escapes ampersands in attributes, so undo that before parsing
+		ref, err := url.Parse(strings.ReplaceAll(raw, "&amp;", "&"))
+		if err != nil || ref.IsAbs() || strings.HasPrefix(raw, "//") {
+			return match
+		}
+
+		resolved := strings.ReplaceAll(base.ResolveReference(ref).String(), "&", "&amp;")
+		return prefix + resolved + `"`
+	})
+}
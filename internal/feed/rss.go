@@ -0,0 +1,99 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+// encoding/xml has no real support for namespace prefixes when marshaling, so the
+// prefixed element and attribute names below are spelled out literally.
+
+type rss struct {
+	XMLName      xml.Name   `xml:"rss"`
+	Version      string     `xml:"version,attr"`
+	XMLNSAtom    string     `xml:"xmlns:atom,attr"`
+	XMLNSContent string     `xml:"xmlns:content,attr"`
+	XMLNSDC      string     `xml:"xmlns:dc,attr"`
+	Channel      rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	AtomLink      rssAtomLink `xml:"atom:link"`
+	LastBuildDate string      `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title          string   `xml:"title"`
+	Link           string   `xml:"link"`
+	Description    string   `xml:"description"`
+	ContentEncoded rssCDATA `xml:"content:encoded"`
+	Creator        string   `xml:"dc:creator,omitempty"`
+	Categories     []string `xml:"category"`
+	PubDate        string   `xml:"pubDate"`
+	GUID           rssGUID  `xml:"guid"`
+}
+
+type rssCDATA struct {
+	Text string `xml:",cdata"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS encodes the feed as RSS 2.0, advertising `selfURL` through atom:link.
+func RSS(f *Feed, selfURL string) ([]byte, error) {
+	items := make([]rssItem, len(f.Entries))
+	for i, e := range f.Entries {
+		items[i] = rssItem{
+			Title:          e.Title,
+			Link:           e.URL,
+			Description:    e.Summary,
+			ContentEncoded: rssCDATA{Text: e.ContentHTML},
+			Creator:        f.Author,
+			Categories:     e.Tags,
+			PubDate:        e.Published.Format(time.RFC1123Z),
+			GUID:           rssGUID{IsPermaLink: e.ID == e.URL, Value: e.ID},
+		}
+	}
+
+	doc := rss{
+		Version:      "2.0",
+		XMLNSAtom:    "http://www.w3.org/2005/Atom",
+		XMLNSContent: "http://purl.org/rss/1.0/modules/content/",
+		XMLNSDC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.SiteURL,
+			Description: f.Description,
+			AtomLink: rssAtomLink{
+				Href: selfURL,
+				Rel:  "self",
+				Type: "application/rss+xml",
+			},
+			Items: items,
+		},
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.Format(time.RFC1123Z)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
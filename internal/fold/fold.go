@@ -0,0 +1,33 @@
+package fold
+
+import "strings"
+
+// foldMap maps accented Latin letters (already lowercased) to their ASCII base so
+// that e.g. "informação" and "informacao" compare equal. It covers what Portuguese,
+// French, Spanish and German need, which is what the blog is written in.
+var foldMap = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae",
+	'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'œ': "oe",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u",
+	'ý': "y", 'ÿ': "y",
+	'ß': "ss",
+}
+
+// String lowercases `s` and folds its accented Latin letters to ASCII. Letters from
+// other scripts are kept as they are.
+func String(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range strings.ToLower(s) {
+		if folded, ok := foldMap[r]; ok {
+			b.WriteString(folded)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
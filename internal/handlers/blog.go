@@ -2,28 +2,42 @@ package handlers
 
 import (
 	"bytes"
-	"context"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/victhorio/jambe-verte/internal"
 	"github.com/victhorio/jambe-verte/internal/cache"
+	"github.com/victhorio/jambe-verte/internal/config"
 	"github.com/victhorio/jambe-verte/internal/content"
+	"github.com/victhorio/jambe-verte/internal/livereload"
 	"github.com/victhorio/jambe-verte/internal/logger"
+	"github.com/victhorio/jambe-verte/internal/preview"
 )
 
 // Template file paths for each template name
 var templateFiles = map[string][]string{
-	"home":  {"templates/base.html", "templates/home.html"},
-	"posts": {"templates/base.html", "templates/posts.html"},
-	"post":  {"templates/base.html", "templates/post.html"},
-	"page":  {"templates/base.html", "templates/page.html"},
+	"home":   {"templates/base.html", "templates/home.html"},
+	"posts":  {"templates/base.html", "templates/posts.html"},
+	"post":   {"templates/base.html", "templates/post.html"},
+	"page":   {"templates/base.html", "templates/page.html"},
+	"search": {"templates/base.html", "templates/search.html"},
 }
 
+const (
+	htmlContentType = "text/html; charset=utf-8"
+
+	// pageCacheControl lets browsers and proxies keep cached routes, but makes them
+	// revalidate on every use since a refresh can change any route at any time. With
+	// ETags, revalidating an unchanged route is a bodyless 304.
+	pageCacheControl = "public, no-cache"
+)
+
 // Handler manages HTTP request handling with hot-reloadable content caching.
 //
 // # Cache Consistency Model
@@ -49,10 +63,32 @@ var templateFiles = map[string][]string{
 type Handler struct {
 	mu        sync.RWMutex
 	cache     *cache.Cache
+	cfg       *config.Config
 	debugMode bool
 
-	// Pre-parsed templates (parsed once at startup, used in production)
+	// previewSigner mints and verifies draft preview links. It's nil unless drafts
+	// are enabled, in which case all preview routes respond with 404.
+	previewSigner *preview.Signer
+
+	// publishTimer fires when the next scheduled post of the current snapshot comes
+	// due. Guarded by mu, see schedulePublishLocked.
+	publishTimer *time.Timer
+
+	// Pre-parsed templates, parsed at startup and re-parsed by HotReload in debug mode.
+	// Guarded by mu.
 	templates map[string]*template.Template
+
+	// cssRebuilds queues background CSS rebuilds for HotReload, see rebuildCSSLoop
+	cssRebuilds chan struct{}
+	// liveReload notifies browsers of hot reloads. Only set in debug mode.
+	liveReload *livereload.Broker
+
+	// ready is set once the initial snapshot is warmed up, see Start
+	ready atomic.Bool
+	// draining is set once the server starts shutting down, see Drain
+	draining atomic.Bool
+	// warming is set on the handlers that warm snapshots up, see pinned
+	warming bool
 }
 
 type HomePageData struct {
@@ -62,11 +98,42 @@ type HomePageData struct {
 type PostsPageData struct {
 	Posts []*content.Post
 	Tag   string
+
+	// Pagination metadata. Page is 1-based, and PrevURL/NextURL are empty on the
+	// first and last pages respectively.
+	Page       int
+	TotalPages int
+	TotalPosts int
+	PrevURL    string
+	NextURL    string
 }
 
-func New(cache *cache.Cache, debugMode bool) (*Handler, error) {
-	templates := make(map[string]*template.Template)
+func New(cache *cache.Cache, cfg *config.Config, debugMode bool) (*Handler, error) {
+	templates, err := parseTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handler{
+		cache:     cache,
+		cfg:       cfg,
+		debugMode: debugMode,
+		templates: templates,
+	}
+	if cfg.Content.Drafts {
+		h.previewSigner = preview.SignerFromEnv()
+	}
+
+	h.mu.Lock()
+	h.schedulePublishLocked(cache)
+	h.mu.Unlock()
+
+	return h, nil
+}
 
+// parseTemplates parses every entry of templateFiles from disk.
+func parseTemplates() (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template)
 	for name, files := range templateFiles {
 		tmpl, err := template.ParseFiles(files...)
 		if err != nil {
@@ -74,116 +141,97 @@ func New(cache *cache.Cache, debugMode bool) (*Handler, error) {
 		}
 		templates[name] = tmpl
 	}
-
-	return &Handler{
-		cache:     cache,
-		debugMode: debugMode,
-		templates: templates,
-	}, nil
+	return templates, nil
 }
 
-// getTemplate returns a template by name. In debug mode, it re-parses from disk
-// to pick up any changes. In production, it returns the cached template.
+// getTemplate returns a pre-parsed template by name. In debug mode the file watcher
+// swaps in freshly parsed templates whenever they change on disk (see HotReload).
 func (h *Handler) getTemplate(name string) (*template.Template, error) {
-	files, ok := templateFiles[name]
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	tmpl, ok := h.templates[name]
 	if !ok {
 		return nil, fmt.Errorf("unknown template: %s", name)
 	}
-	if !h.debugMode {
-		return h.templates[name], nil
-	}
-	return template.ParseFiles(files...)
+	return tmpl, nil
 }
 
-func (h *Handler) getCache() (*cache.Cache, error) {
-	// In debug mode, reload content from disk for hot-reload
-	if h.debugMode {
-		posts, err := content.LoadContent("content/posts", true)
-		if err != nil {
-			return nil, fmt.Errorf("loading posts: %w", err)
-		}
-		pages, err := content.LoadContent("content/pages", false)
-		if err != nil {
-			return nil, fmt.Errorf("loading pages: %w", err)
-		}
-		return cache.New(posts, pages), nil
-	}
+// getCache returns the current snapshot. In debug mode it's kept up to date by the
+// file watcher rather than reloaded on every request.
+func (h *Handler) getCache() *cache.Cache {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.cache, nil
+	return h.cache
+}
+
+// postLoadOptions returns the options used whenever posts are (re)loaded from disk.
+func (h *Handler) postLoadOptions() content.LoadOptions {
+	return content.LoadOptions{
+		IncludeDrafts: h.cfg.Content.Drafts,
+		Workers:       h.cfg.Content.Workers,
+		Strict:        h.cfg.Content.Strict,
+	}
+}
+
+// pageLoadOptions returns the options used whenever pages are (re)loaded from disk.
+func (h *Handler) pageLoadOptions() content.LoadOptions {
+	return content.LoadOptions{Workers: h.cfg.Content.Workers, Strict: h.cfg.Content.Strict}
 }
 
 func (h *Handler) setCache(cache *cache.Cache) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if h.cache != nil {
+		cache.GetPageCache().Inherit(h.cache.GetPageCache())
+	}
 	h.cache = cache
+	h.schedulePublishLocked(cache)
 }
 
 func (h *Handler) Home(w http.ResponseWriter, r *http.Request) {
-	c, err := h.getCache()
-	if err != nil {
-		logger.WithRequest(r.Context()).Error("Failed to load content", "error", err)
-		internal.WriteInternalError(w, "JVE-IHB-LC")
-		return
-	}
+	c := h.getCache()
 	pageCache := c.GetPageCache()
 
 	// Check page cache first, unless we're in debug mode
-	if !h.debugMode {
-		if cached, ok := pageCache.Get("/"); ok {
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.Write(cached)
-			return
-		}
+	if !h.debugMode && h.serveCached(w, r, pageCache, "/") {
+		return
 	}
 
-	// Get up to 2 most recent posts
+	// Get the configured number of most recent posts
 	posts := c.GetPosts()
 	recentPosts := posts
-	if len(posts) > 2 {
-		recentPosts = posts[:2]
+	if len(posts) > h.cfg.Pagination.HomePosts {
+		recentPosts = posts[:h.cfg.Pagination.HomePosts]
 	}
 
 	data := HomePageData{
 		RecentPosts: recentPosts,
 	}
 
-	h.renderAndCache(r.Context(), w, pageCache, "/", "home", data)
+	h.renderAndCache(w, r, pageCache, "/", "home", data)
 }
 
 func (h *Handler) ListPosts(w http.ResponseWriter, r *http.Request) {
-	c, err := h.getCache()
-	if err != nil {
-		logger.WithRequest(r.Context()).Error("Failed to load content", "error", err)
-		internal.WriteInternalError(w, "JVE-IHB-LC")
-		return
-	}
-	pageCache := c.GetPageCache()
+	h.listPosts(w, r, "", 1)
+}
 
-	// Check page cache first, unless we're in debug mode
-	if !h.debugMode {
-		if cached, ok := pageCache.Get("/posts"); ok {
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.Write(cached)
-			return
-		}
+func (h *Handler) ListPostsPage(w http.ResponseWriter, r *http.Request) {
+	n, ok := parsePageNumber(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
 	}
-
-	data := PostsPageData{
-		Posts: c.GetPosts(),
+	if n == 1 {
+		http.Redirect(w, r, "/posts", http.StatusMovedPermanently)
+		return
 	}
-
-	h.renderAndCache(r.Context(), w, pageCache, "/posts", "posts", data)
+	h.listPosts(w, r, "", n)
 }
 
 func (h *Handler) ShowPost(w http.ResponseWriter, r *http.Request) {
 	slug := chi.URLParam(r, "slug")
-	c, err := h.getCache()
-	if err != nil {
-		logger.WithRequest(r.Context()).Error("Failed to load content", "error", err)
-		internal.WriteInternalError(w, "JVE-IHB-LC")
-		return
-	}
+	c := h.getCache()
 	pageCache := c.GetPageCache()
 
 	post, ok := c.GetPost(slug)
@@ -194,25 +242,16 @@ func (h *Handler) ShowPost(w http.ResponseWriter, r *http.Request) {
 
 	// Check page cache first, unless we're in debug mode
 	route := "/blog/" + slug
-	if !h.debugMode {
-		if cached, ok := pageCache.Get(route); ok {
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.Write(cached)
-			return
-		}
+	if !h.debugMode && h.serveCached(w, r, pageCache, route) {
+		return
 	}
 
-	h.renderAndCache(r.Context(), w, pageCache, route, "post", post)
+	h.renderAndCache(w, r, pageCache, route, "post", post)
 }
 
 func (h *Handler) ShowPage(w http.ResponseWriter, r *http.Request) {
 	slug := chi.URLParam(r, "page")
-	c, err := h.getCache()
-	if err != nil {
-		logger.WithRequest(r.Context()).Error("Failed to load content", "error", err)
-		internal.WriteInternalError(w, "JVE-IHB-LC")
-		return
-	}
+	c := h.getCache()
 	pageCache := c.GetPageCache()
 
 	page, ok := c.GetPage(slug)
@@ -223,86 +262,182 @@ func (h *Handler) ShowPage(w http.ResponseWriter, r *http.Request) {
 
 	// Check page cache first, unless we're in debug mode
 	route := "/" + slug
-	if !h.debugMode {
-		if cached, ok := pageCache.Get(route); ok {
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.Write(cached)
-			return
-		}
+	if !h.debugMode && h.serveCached(w, r, pageCache, route) {
+		return
 	}
 
-	h.renderAndCache(r.Context(), w, pageCache, route, "page", page)
+	h.renderAndCache(w, r, pageCache, route, "page", page)
 }
 
 func (h *Handler) PostsByTag(w http.ResponseWriter, r *http.Request) {
+	h.listPosts(w, r, chi.URLParam(r, "tag"), 1)
+}
+
+func (h *Handler) PostsByTagPage(w http.ResponseWriter, r *http.Request) {
 	tag := chi.URLParam(r, "tag")
-	c, err := h.getCache()
-	if err != nil {
-		logger.WithRequest(r.Context()).Error("Failed to load content", "error", err)
-		internal.WriteInternalError(w, "JVE-IHB-LC")
+	n, ok := parsePageNumber(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if n == 1 {
+		http.Redirect(w, r, tagRoute(tag), http.StatusMovedPermanently)
 		return
 	}
+	h.listPosts(w, r, tag, n)
+}
+
+// listPosts renders page `n` of either every post (empty `tag`) or the posts with
+// `tag`. Each page is cached under its own route, and pages past the end are a 404.
+func (h *Handler) listPosts(w http.ResponseWriter, r *http.Request, tag string, n int) {
+	c := h.getCache()
 	pageCache := c.GetPageCache()
 
-	posts := c.GetPostsByTag(tag)
-	if len(posts) == 0 {
+	posts := c.GetPosts()
+	baseRoute := "/posts"
+	if tag != "" {
+		posts = c.GetPostsByTag(tag)
+		baseRoute = tagRoute(tag)
+		if len(posts) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	data, ok := paginate(posts, n, h.cfg.Pagination.PageSize, baseRoute)
+	if !ok {
 		http.NotFound(w, r)
 		return
 	}
+	data.Tag = tag
 
 	// Check page cache first, unless we're in debug mode
-	route := "/tag/" + tag
-	if !h.debugMode {
-		if cached, ok := pageCache.Get(route); ok {
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.Write(cached)
-			return
-		}
+	route := pageRoute(baseRoute, n)
+	if !h.debugMode && h.serveCached(w, r, pageCache, route) {
+		return
 	}
 
-	data := PostsPageData{
-		Posts: posts,
-		Tag:   tag,
-	}
+	h.renderAndCache(w, r, pageCache, route, "posts", data)
+}
 
-	h.renderAndCache(r.Context(), w, pageCache, route, "posts", data)
+// RefreshReport describes what an AdminRefresh picked up.
+type RefreshReport struct {
+	content.Changes
+	Posts     int `json:"posts"`
+	Scheduled int `json:"scheduled"`
+	Pages     int `json:"pages"`
+	// CarriedOver is how many rendered routes were kept from the previous snapshot.
+	CarriedOver int `json:"carriedOver"`
+	// WarmedUp is how many routes were cached before the new snapshot was published.
+	WarmedUp int `json:"warmedUp"`
 }
 
 // AdminRefresh is responsible for hot-reloading content by creating an entirely new cache
-// and replacing it on the handler.
+// and replacing it on the handler. Only files that changed since the current snapshot
+// was loaded are rendered again, and rendered routes that don't depend on any of them
+// are carried over to the new snapshot's PageCache, see carryOver.
 func (h *Handler) AdminRefresh(w http.ResponseWriter, r *http.Request) {
 	log := logger.WithRequest(r.Context())
 
-	// Load posts
-	posts, err := content.LoadContent("content/posts", true)
-	if err != nil {
-		log.Error("Error loading posts during refresh", "error", err)
-		internal.WriteInternalError(w, "JVE-IHB-PO")
+	c := h.getCache()
+
+	newCache, changes, err := h.loadSnapshot(c, true, true)
+	if fileErrs := content.FileErrors(err); h.cfg.Content.Strict && len(fileErrs) > 0 {
+		// Keep serving the current snapshot rather than dropping the broken files
+		log.Error("Refusing to refresh invalid content", "error", err)
+		refreshes.Inc("invalid")
+		writeValidationReport(w, fileErrs)
 		return
 	}
-
-	// Load pages
-	pages, err := content.LoadContent("content/pages", false)
 	if err != nil {
-		log.Error("Error loading pages during refresh", "error", err)
-		internal.WriteInternalError(w, "JVE-IHB-PA")
+		log.Error("Error loading content during refresh", "error", err)
+		refreshes.Inc("failure")
+		internal.WriteInternalError(w, "JVE-IHB-RL")
 		return
 	}
 
-	// Create new cache and replace the old one
-	newCache := cache.New(posts, pages)
+	// Carry over still valid pages, warm up the rest and replace the old cache
+	report := RefreshReport{
+		Changes:     changes,
+		Posts:       len(newCache.GetPosts()),
+		Scheduled:   len(newCache.GetScheduled()),
+		Pages:       len(newCache.GetPages()),
+		CarriedOver: h.carryOver(c, newCache),
+	}
+	report.WarmedUp = h.warmUp(r.Context(), newCache)
 	h.setCache(newCache)
-
-	log.Info("Cache refreshed successfully", "posts", len(posts), "pages", len(pages))
+	refreshes.Inc("success")
+
+	log.Info(
+		"Cache refreshed successfully",
+		"posts", report.Posts,
+		"scheduled", report.Scheduled,
+		"pages", report.Pages,
+		"added", len(changes.Added),
+		"changed", len(changes.Changed),
+		"removed", len(changes.Removed),
+		"carried_over", report.CarriedOver,
+		"warmed_up", report.WarmedUp,
+	)
 
 	// Also attempt to rebuild CSS
 	content.RebuildCSS(r.Context())
 
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Write([]byte("OK"))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Error("Failed to write refresh report", "error", err)
+	}
+}
+
+// AdminCache reports the counters of the page cache, which carry on across refreshes,
+// along with what the current snapshot holds.
+func (h *Handler) AdminCache(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithRequest(r.Context())
+
+	c := h.getCache()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c.GetPageCache().Stats()); err != nil {
+		log.Error("Failed to write cache stats", "error", err)
+	}
+}
+
+// ValidationIssue is a single problem with a content file, see content.FileError.
+type ValidationIssue struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationReport lists every problem that prevented a strict refresh.
+type ValidationReport struct {
+	Errors []ValidationIssue `json:"errors"`
+}
+
+// writeValidationReport responds with 422 and a ValidationReport for `fileErrs`.
+func writeValidationReport(w http.ResponseWriter, fileErrs []*content.FileError) {
+	report := ValidationReport{Errors: make([]ValidationIssue, len(fileErrs))}
+	for i, fileErr := range fileErrs {
+		report.Errors[i] = ValidationIssue{
+			Path:    fileErr.Path,
+			Line:    fileErr.Line,
+			Message: fileErr.Err.Error(),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(report)
 }
 
-func (h *Handler) renderAndCache(ctx context.Context, w http.ResponseWriter, pageCache *cache.PageCache, route string, templateName string, data any) {
+// renderAndCache renders `templateName` with `data` into `w`, storing the result on
+// `pageCache` under `route`. A nil `pageCache` renders without caching, which is
+// what routes serving per-request content (such as draft previews) should use.
+//
+// Concurrent requests missing the same route of the same snapshot share a single
+// render, see cache.PageCache.Render.
+func (h *Handler) renderAndCache(w http.ResponseWriter, r *http.Request, pageCache *cache.PageCache, route string, templateName string, data any) {
+	ctx := r.Context()
 	log := logger.WithRequest(ctx)
 
 	// Check if context is cancelled
@@ -313,12 +448,7 @@ func (h *Handler) renderAndCache(ctx context.Context, w http.ResponseWriter, pag
 	default:
 	}
 
-	// In debug mode, rebuild CSS to pick up any new Tailwind classes
-	if h.debugMode {
-		content.RebuildCSS(ctx)
-	}
-
-	// Get template (fresh parse in debug mode, cached in production)
+	// Get the pre-parsed template
 	tmpl, err := h.getTemplate(templateName)
 	if err != nil {
 		log.Error("Template parsing failed", "error", err, "template", templateName)
@@ -326,39 +456,139 @@ func (h *Handler) renderAndCache(ctx context.Context, w http.ResponseWriter, pag
 		return
 	}
 
-	startTime := time.Now()
+	execute := func() ([]byte, error) {
+		startTime := time.Now()
+
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, "base", map[string]any{
+			"DebugMode": h.debugMode,
+			"Version":   internal.Version,
+			"Site":      h.cfg.Site,
+			"Data":      data,
+		}); err != nil {
+			return nil, err
+		}
+
+		duration := time.Since(startTime)
+		renderDuration.Observe(duration.Seconds(), templateName)
+		log.Info("Rendered route in cold path", "route", route, "duration", duration.String())
+		return buf.Bytes(), nil
+	}
 
-	// Execute the template
-	var buf bytes.Buffer
-	if err := tmpl.ExecuteTemplate(&buf, "base", map[string]any{
-		"DebugMode": h.debugMode,
-		"Version":   internal.Version,
-		"Data":      data,
-	}); err != nil {
+	// Skip caching in debug mode
+	if h.debugMode || pageCache == nil {
+		rendered, err := execute()
+		if err != nil {
+			log.Error("Template execution failed", "error", err, "template", templateName)
+			internal.WriteInternalError(w, "JVE-IHB-TX")
+			return
+		}
+		w.Header().Set("Content-Type", htmlContentType)
+		if _, err := w.Write(rendered); err != nil {
+			log.Error("Failed to write rendered response", "error", err)
+		}
+		return
+	}
+
+	entry, err := pageCache.Render(ctx, route, htmlContentType, execute)
+	if ctx.Err() != nil {
+		log.Warn("Request cancelled before response", "error", ctx.Err())
+		return
+	}
+	if err != nil {
 		log.Error("Template execution failed", "error", err, "template", templateName)
 		internal.WriteInternalError(w, "JVE-IHB-TX")
 		return
 	}
+	serveEntry(w, r, entry)
+}
 
-	duration := time.Since(startTime)
-	log.Info("Rendered route in cold path", "route", route, "duration", duration.String())
+// generateAndCache serves non-template output (feeds, sitemaps, ...) from `pageCache`,
+// calling `generate` on a miss and caching its result. Like renderAndCache, concurrent
+// misses share a single call. Debug mode always regenerates.
+//
+// Such output embeds absolute URLs, so unless the base URL is pinned by the config it
+// depends on the request's Host and is regenerated every time. Caching it per origin
+// would let any client fill the cache with made-up hosts.
+func (h *Handler) generateAndCache(w http.ResponseWriter, r *http.Request, pageCache *cache.PageCache, cacheKey string, contentType string, errorCode string, generate func() ([]byte, error)) {
+	ctx := r.Context()
+	log := logger.WithRequest(ctx)
 
-	// Cache the rendered content (skip caching in debug mode)
-	rendered := buf.Bytes()
-	if !h.debugMode {
-		pageCache.Set(route, rendered)
+	timed := func() ([]byte, error) {
+		start := time.Now()
+		defer func() {
+			generateDuration.Observe(time.Since(start).Seconds(), mediaType(contentType))
+		}()
+		return generate()
 	}
 
-	// Check if context is cancelled before writing response
-	select {
-	case <-ctx.Done():
+	if h.debugMode || h.cfg.Site.BaseURL == "" {
+		rendered, err := timed()
+		if err != nil {
+			log.Error("Failed to generate route", "error", err, "key", cacheKey)
+			internal.WriteInternalError(w, errorCode)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		if _, err := w.Write(rendered); err != nil {
+			log.Error("Failed to write generated response", "error", err)
+		}
+		return
+	}
+
+	// Check page cache first
+	if h.serveCached(w, r, pageCache, cacheKey) {
+		return
+	}
+
+	entry, err := pageCache.Render(ctx, cacheKey, contentType, timed)
+	if ctx.Err() != nil {
 		log.Warn("Request cancelled before response", "error", ctx.Err())
 		return
-	default:
 	}
+	if err != nil {
+		log.Error("Failed to generate route", "error", err, "key", cacheKey)
+		internal.WriteInternalError(w, errorCode)
+		return
+	}
+	serveEntry(w, r, entry)
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if _, err := w.Write(rendered); err != nil {
-		log.Error("Failed to write cached response", "error", err)
+// serveCached serves the entry cached under `key`, if there's one, reporting whether
+// it did. Warm-up lookups aren't counted in the PageCache stats.
+func (h *Handler) serveCached(w http.ResponseWriter, r *http.Request, pageCache *cache.PageCache, key string) bool {
+	lookup := pageCache.Get
+	if h.warming {
+		lookup = pageCache.Peek
+	}
+	entry, ok := lookup(key)
+	if ok {
+		serveEntry(w, r, entry)
 	}
+	return ok
+}
+
+// serveEntry writes a cached entry along with its validators, answering conditional
+// requests (If-None-Match, If-Modified-Since) with 304 Not Modified. Clients that
+// accept one of the entry's precompressed copies get that copy instead of the body,
+// under its own ETag.
+func serveEntry(w http.ResponseWriter, r *http.Request, entry *cache.Entry) {
+	var offered []string
+	for _, encoding := range cache.Encodings {
+		if _, ok := entry.Encoded[encoding]; ok {
+			offered = append(offered, encoding)
+		}
+	}
+
+	body, etag := entry.Body, entry.ETag
+	if encoding := negotiateEncoding(r, offered); encoding != "" {
+		body, etag = entry.Encoded[encoding], entry.VariantETag(encoding)
+		w.Header().Set("Content-Encoding", encoding)
+	}
+
+	w.Header().Set("Content-Type", entry.ContentType)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", pageCacheControl)
+	w.Header().Add("Vary", "Accept-Encoding")
+	http.ServeContent(w, r, "", entry.ModTime, bytes.NewReader(body))
 }
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// negotiateEncoding picks the first of `offered` that the Accept-Encoding header of `r`
+// accepts, so `offered` should be in order of preference. It returns "" when the body
+// should be sent as is.
+func negotiateEncoding(r *http.Request, offered []string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	for _, field := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(field, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		accepted[coding] = q
+	}
+
+	for _, coding := range offered {
+		q, ok := accepted[coding]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > 0 {
+			return coding
+		}
+	}
+	return ""
+}
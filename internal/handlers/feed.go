@@ -1,89 +1,119 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/xml"
+	"fmt"
 	"net/http"
-	"time"
 
-	"github.com/victhorio/jambe-verte/internal"
-	"github.com/victhorio/jambe-verte/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/victhorio/jambe-verte/internal/feed"
 )
 
-type RSS struct {
-	XMLName xml.Name `xml:"rss"`
-	Version string   `xml:"version,attr"`
-	Channel Channel  `xml:"channel"`
+// feedFormat describes how to serve one of the feed flavours built from feed.Feed.
+type feedFormat struct {
+	name        string
+	contentType string
+	encode      func(f *feed.Feed, selfURL string) ([]byte, error)
+	// errorCode is reported to the client when encoding fails
+	errorCode string
 }
 
-type Channel struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	Items       []Item `xml:"item"`
+var (
+	rssFormat = feedFormat{
+		name:        "rss",
+		contentType: "application/rss+xml; charset=utf-8",
+		encode:      feed.RSS,
+		errorCode:   "JVE-IHF-XE",
+	}
+	atomFormat = feedFormat{
+		name:        "atom",
+		contentType: "application/atom+xml; charset=utf-8",
+		encode:      feed.Atom,
+		errorCode:   "JVE-IHF-AE",
+	}
+	jsonFeedFormat = feedFormat{
+		name:        "json",
+		contentType: "application/feed+json; charset=utf-8",
+		encode:      feed.JSON,
+		errorCode:   "JVE-IHF-JE",
+	}
+)
+
+func (h *Handler) RSSFeed(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, "/feed.xml", rssFormat, "")
 }
 
-type Item struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-	GUID        string `xml:"guid"`
+func (h *Handler) AtomFeed(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, "/atom.xml", atomFormat, "")
 }
 
-func (h *Handler) RSSFeed(w http.ResponseWriter, r *http.Request) {
-	c, err := h.getCache()
-	if err != nil {
-		logger.WithRequest(r.Context()).Error("Failed to load content", "error", err)
-		internal.WriteInternalError(w, "JVE-IHF-LC")
-		return
-	}
+func (h *Handler) JSONFeed(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, "/feed.json", jsonFeedFormat, "")
+}
+
+func (h *Handler) TagRSSFeed(w http.ResponseWriter, r *http.Request) {
+	tag := chi.URLParam(r, "tag")
+	h.serveFeed(w, r, tagRoute(tag)+"/feed.xml", rssFormat, tag)
+}
+
+func (h *Handler) TagAtomFeed(w http.ResponseWriter, r *http.Request) {
+	tag := chi.URLParam(r, "tag")
+	h.serveFeed(w, r, tagRoute(tag)+"/atom.xml", atomFormat, tag)
+}
+
+func (h *Handler) TagJSONFeed(w http.ResponseWriter, r *http.Request) {
+	tag := chi.URLParam(r, "tag")
+	h.serveFeed(w, r, tagRoute(tag)+"/feed.json", jsonFeedFormat, tag)
+}
+
+// serveFeed renders a feed in the given format, caching the result in the current
+// snapshot's PageCache just like HTML routes. An empty `tag` means the site-wide
+// feed; otherwise only posts with that tag are included.
+func (h *Handler) serveFeed(w http.ResponseWriter, r *http.Request, route string, format feedFormat, tag string) {
+	c := h.getCache()
+	pageCache := c.GetPageCache()
 
-	// Get recent posts (max 20)
 	posts := c.GetPosts()
-	if len(posts) > 20 {
-		posts = posts[:20]
+	if tag != "" {
+		posts = c.GetPostsByTag(tag)
+		if len(posts) == 0 {
+			http.NotFound(w, r)
+			return
+		}
 	}
 
-	// Determine the scheme from the request
-	scheme := "http"
-	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
-		scheme = "https"
-	}
-	baseURL := scheme + "://" + r.Host
-
-	// Build RSS items
-	items := make([]Item, len(posts))
-	for i, post := range posts {
-		postURL := baseURL + "/blog/" + post.Slug
-		items[i] = Item{
-			Title:       post.Title,
-			Link:        postURL,
-			Description: post.Description,
-			PubDate:     post.Date.Format(time.RFC1123Z),
-			GUID:        postURL, // Use URL as unique identifier
+	baseURL := h.baseURL(r)
+	h.generateAndCache(w, r, pageCache, route, format.contentType, format.errorCode, func() ([]byte, error) {
+		// Keep only the most recent posts, up to the configured feed limit
+		if len(posts) > h.cfg.Feed.Limit {
+			posts = posts[:h.cfg.Feed.Limit]
 		}
-	}
 
-	rss := RSS{
-		Version: "2.0",
-		Channel: Channel{
-			Title:       "Jambe Verte",
-			Link:        baseURL,
-			Description: "A blog by Victhor Sartório",
-			Items:       items,
-		},
-	}
+		site := h.cfg.Site
+		f := feed.New(site.Title, site.Description, site.Author, baseURL, posts)
+		if tag != "" {
+			f.Title = site.Title + " - #" + tag
+			f.SiteURL = baseURL + tagRoute(tag)
+		}
 
-	// Buffer the XML output first so we can return a proper error status if encoding fails
-	var buf bytes.Buffer
-	buf.WriteString(xml.Header)
-	if err := xml.NewEncoder(&buf).Encode(rss); err != nil {
-		logger.WithRequest(r.Context()).Error("Failed to encode RSS feed", "error", err, "posts_count", len(posts))
-		internal.WriteInternalError(w, "JVE-IHF-XE")
-		return
+		rendered, err := format.encode(f, baseURL+route)
+		if err != nil {
+			return nil, fmt.Errorf("encoding %s feed with %d posts: %w", format.name, len(posts), err)
+		}
+		return rendered, nil
+	})
+}
+
+// baseURL returns the public origin of the site. The configured base URL takes
+// precedence; otherwise it's derived from the request's scheme and host.
+func (h *Handler) baseURL(r *http.Request) string {
+	if h.cfg.Site.BaseURL != "" {
+		return h.cfg.Site.BaseURL
 	}
 
-	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
-	w.Write(buf.Bytes())
+	// Determine the scheme from the request
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
 }
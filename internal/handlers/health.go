@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/victhorio/jambe-verte/internal"
+	"github.com/victhorio/jambe-verte/internal/logger"
+)
+
+// probeCacheControl keeps probe responses out of every cache, since they must always
+// reflect the current state of the process.
+const probeCacheControl = "no-store"
+
+// Healthz reports that the process is alive and serving HTTP. Unlike Readyz, it keeps
+// succeeding while shutting down, so that supervisors don't kill a draining server.
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", probeCacheControl)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// Readyz reports whether the server should receive traffic: content is loaded and
+// templates are parsed (which New guarantees), the initial warm-up is done (see Start)
+// and the server isn't shutting down (see Drain).
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", probeCacheControl)
+
+	reason := ""
+	switch {
+	case h.draining.Load():
+		reason = "shutting down"
+	case !h.Ready():
+		reason = "warming up"
+	}
+	if reason != "" {
+		http.Error(w, "not ready: "+reason, http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// Drain makes Readyz fail from now on, so that load balancers stop sending traffic
+// before the server shuts down.
+func (h *Handler) Drain() {
+	h.draining.Store(true)
+}
+
+// VersionInfo describes the running build.
+type VersionInfo struct {
+	Version string `json:"version"`
+	// Revision is the VCS revision the binary was built from, and Modified whether the
+	// working tree had uncommitted changes. Both are empty when the binary was built
+	// without VCS information (e.g. with -buildvcs=false).
+	Revision string `json:"revision,omitempty"`
+	Modified bool   `json:"modified,omitempty"`
+	// BuildTime is the time of the revision's commit, which is the closest to a build
+	// time that Go records.
+	BuildTime string `json:"buildTime,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+// buildVersion gathers the VersionInfo of the running binary.
+func buildVersion() VersionInfo {
+	info := VersionInfo{Version: internal.Version, GoVersion: runtime.Version()}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if build.GoVersion != "" {
+		info.GoVersion = build.GoVersion
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.BuildTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// Version responds with the VersionInfo of the running binary.
+func (h *Handler) Version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", probeCacheControl)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(buildVersion()); err != nil {
+		logger.WithRequest(r.Context()).Error("Failed to write version", "error", err)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"mime"
+
+	"github.com/victhorio/jambe-verte/internal/metrics"
+)
+
+var (
+	renderDuration = metrics.NewHistogramVec(
+		"jv_render_duration_seconds",
+		"Time taken to render a route on a page cache miss, by template.",
+		metrics.DefaultBuckets, "template",
+	)
+	generateDuration = metrics.NewHistogramVec(
+		"jv_generate_duration_seconds",
+		"Time taken to generate non-template output (feeds, sitemaps, robots.txt) on a page cache miss, by content type.",
+		metrics.DefaultBuckets, "content_type",
+	)
+	refreshes = metrics.NewCounterVec(
+		"jv_refreshes_total",
+		"Admin refreshes by outcome: success, invalid (rejected by strict validation) or failure.",
+		"result",
+	)
+)
+
+// mediaType strips the parameters off `contentType`, to keep metric labels short.
+func mediaType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	return contentType
+}
+
+// RegisterMetrics registers the metrics describing the handler's current snapshot on
+// `reg`. It must be called at most once per registry.
+func (h *Handler) RegisterMetrics(reg *metrics.Registry) {
+	current := h.getCache
+
+	reg.NewGaugeFunc("jv_posts", "Published posts in the current snapshot.", func() float64 {
+		return float64(len(current().GetPosts()))
+	})
+	reg.NewGaugeFunc("jv_scheduled_posts", "Posts waiting to be published in the current snapshot.", func() float64 {
+		return float64(len(current().GetScheduled()))
+	})
+	reg.NewGaugeFunc("jv_pages", "Pages in the current snapshot.", func() float64 {
+		return float64(len(current().GetPages()))
+	})
+
+	// The counters are shared by every snapshot, see cache.Stats. The hit ratio is
+	// meant to be derived from them, e.g. with rate() over hits and misses.
+	reg.NewCounterFunc("jv_page_cache_hits_total", "Page cache lookups that found a rendered route.", func() float64 {
+		return float64(current().GetPageCache().Stats().Hits)
+	})
+	reg.NewCounterFunc("jv_page_cache_misses_total", "Page cache lookups that had to render the route.", func() float64 {
+		return float64(current().GetPageCache().Stats().Misses)
+	})
+	reg.NewCounterFunc("jv_page_cache_evictions_total", "Rendered routes evicted to keep the page cache within its budget.", func() float64 {
+		return float64(current().GetPageCache().Stats().Evictions)
+	})
+	reg.NewGaugeFunc("jv_page_cache_entries", "Rendered routes held by the current snapshot's page cache.", func() float64 {
+		return float64(current().GetPageCache().Stats().Entries)
+	})
+	reg.NewGaugeFunc("jv_page_cache_resident_bytes", "Memory taken by the current snapshot's page cache.", func() float64 {
+		return float64(current().GetPageCache().Stats().ResidentBytes)
+	})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/victhorio/jambe-verte/internal/cache"
+	"github.com/victhorio/jambe-verte/internal/config"
+	"github.com/victhorio/jambe-verte/internal/logger"
+	"github.com/victhorio/jambe-verte/internal/metrics"
+	"github.com/victhorio/jambe-verte/internal/middleware"
+)
+
+// TestMetricsExposition serves a few requests through the request logger and the
+// page cache, then checks that a scrape exposes them in the Prometheus text format.
+func TestMetricsExposition(t *testing.T) {
+	// Templates are parsed relative to the repository root
+	t.Chdir("../..")
+
+	defaultLogger := logger.Logger
+	logger.Logger = slog.New(slog.DiscardHandler)
+	t.Cleanup(func() { logger.Logger = defaultLogger })
+
+	cfg := config.Default()
+	cfg.Cache.WarmUp.Enabled = false
+	c, err := NewSnapshot(cache.Sources{PageStats: &cache.Stats{}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := New(c, cfg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A registry of its own keeps the counters from leaking across tests and runs
+	reg := metrics.NewRegistry()
+	h.RegisterMetrics(reg)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestLogger(reg))
+	h.Routes(r)
+	r.Method(http.MethodGet, "/metrics", reg.Handler())
+
+	// A miss, then a hit
+	for range 2 {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET / responded with %d", rec.Code)
+		}
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/", nil))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics responded with %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	exposition := string(body)
+
+	for _, want := range []string{
+		"# TYPE jv_http_requests_total counter",
+		`jv_http_requests_total{method="GET",route="/",status="200"} 2`,
+		`jv_http_requests_total{method="other",route="unmatched",status="405"} 1`,
+		"# TYPE jv_http_request_duration_seconds histogram",
+		`jv_http_request_duration_seconds_bucket{route="/",status="200",le="+Inf"} 2`,
+		`jv_http_request_duration_seconds_count{route="/",status="200"} 2`,
+		"# TYPE jv_page_cache_hits_total counter",
+		"jv_page_cache_hits_total 1",
+		"jv_page_cache_misses_total 1",
+		"# TYPE jv_page_cache_entries gauge",
+		"jv_page_cache_entries 1",
+		"# TYPE jv_page_cache_resident_bytes gauge",
+	} {
+		if !strings.Contains(exposition, want+"\n") {
+			t.Errorf("exposition is missing %q", want)
+		}
+	}
+	if strings.Contains(exposition, "jv_page_cache_resident_bytes 0\n") {
+		t.Error("jv_page_cache_resident_bytes doesn't account for the cached page")
+	}
+
+	// Every sample line must be a metric name, optional labels and a value
+	for _, line := range strings.Split(strings.TrimSpace(exposition), "\n") {
+		if strings.HasPrefix(line, "# ") {
+			continue
+		}
+		name, _, _ := strings.Cut(line, "{")
+		if i := strings.IndexByte(name, ' '); i >= 0 {
+			name = name[:i]
+		}
+		if !strings.HasPrefix(name, "jv_") || !strings.Contains(line, " ") {
+			t.Errorf("malformed sample line %q", line)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/victhorio/jambe-verte/internal/content"
+)
+
+// totalPages returns how many pages of `size` posts are needed. An empty listing
+// still has a single (empty) page.
+func totalPages(count, size int) int {
+	return max(1, (count+size-1)/size)
+}
+
+// pageRoute returns the route of 1-based page `n` of the listing at `baseRoute`. The
+// first page lives at the base route itself.
+func pageRoute(baseRoute string, n int) string {
+	if n == 1 {
+		return baseRoute
+	}
+	return baseRoute + "/page/" + strconv.Itoa(n)
+}
+
+// paginate slices out page `n` of `posts`, reporting false when it's out of range.
+func paginate(posts []*content.Post, n, size int, baseRoute string) (PostsPageData, bool) {
+	total := totalPages(len(posts), size)
+	if n < 1 || n > total {
+		return PostsPageData{}, false
+	}
+
+	start := (n - 1) * size
+	end := min(start+size, len(posts))
+
+	data := PostsPageData{
+		Posts:      posts[start:end],
+		Page:       n,
+		TotalPages: total,
+		TotalPosts: len(posts),
+	}
+	if n > 1 {
+		data.PrevURL = pageRoute(baseRoute, n-1)
+	}
+	if n < total {
+		data.NextURL = pageRoute(baseRoute, n+1)
+	}
+	return data, true
+}
+
+// parsePageNumber reads the `{n}` route parameter, rejecting anything that isn't a
+// plain positive integer (so `/page/02` and `/page/+2` don't alias `/page/2`).
+func parsePageNumber(r *http.Request) (int, bool) {
+	raw := chi.URLParam(r, "n")
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || strconv.Itoa(n) != raw {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/victhorio/jambe-verte/internal/logger"
+)
+
+type PreviewLink struct {
+	Slug      string    `json:"slug"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ShowPreview renders a draft post for anyone holding a valid signed link. Invalid,
+// expired or forged links get a plain 404 so that they don't reveal which drafts exist.
+func (h *Handler) ShowPreview(w http.ResponseWriter, r *http.Request) {
+	if h.previewSigner == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	log := logger.WithRequest(r.Context())
+	slug := chi.URLParam(r, "slug")
+
+	if err := h.previewSigner.Verify(slug, r.URL.Query().Get("sig"), time.Now()); err != nil {
+		log.Warn("Rejected preview link", "slug", slug, "error", err)
+		http.NotFound(w, r)
+		return
+	}
+
+	c := h.getCache()
+
+	post, ok := c.GetDraft(slug)
+	if !ok {
+		// The draft may have been published since the link was minted
+		if _, ok := c.GetPost(slug); ok {
+			http.Redirect(w, r, "/blog/"+slug, http.StatusFound)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	// Previews must never end up in shared caches or search engines
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	h.renderAndCache(w, r, nil, r.URL.Path, "post", post)
+}
+
+// AdminPreview mints a signed preview link for the draft given by the `slug` form
+// value. An optional `ttl` (Go duration syntax) overrides the configured default.
+func (h *Handler) AdminPreview(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithRequest(r.Context())
+
+	if h.previewSigner == nil {
+		http.Error(w, "Drafts are disabled", http.StatusNotFound)
+		return
+	}
+
+	slug := r.FormValue("slug")
+	if slug == "" {
+		http.Error(w, "Missing slug", http.StatusBadRequest)
+		return
+	}
+
+	ttl := h.cfg.Preview.DefaultTTL
+	if raw := r.FormValue("ttl"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid ttl", http.StatusBadRequest)
+			return
+		}
+		ttl = min(parsed, h.cfg.Preview.MaxTTL)
+	}
+
+	c := h.getCache()
+	if _, ok := c.GetDraft(slug); !ok {
+		http.Error(w, "No draft with that slug", http.StatusNotFound)
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	sig := h.previewSigner.Sign(slug, expiresAt)
+	link := PreviewLink{
+		Slug:      slug,
+		URL:       h.baseURL(r) + "/preview/" + url.PathEscape(slug) + "?sig=" + url.QueryEscape(sig),
+		ExpiresAt: expiresAt,
+	}
+
+	log.Info("Minted preview link", "slug", slug, "expires_at", expiresAt)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(link); err != nil {
+		log.Error("Failed to write preview link", "error", err)
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Redirects is a middleware that applies the redirect rules of the current snapshot
+// and sends requests for the aliases of a post or page to its canonical URL. Rules
+// take precedence over every route the middleware wraps, while aliases never shadow
+// live routes since the cache refuses aliases that conflict with them. It should wrap
+// the NotFound handler too, so that paths no route matches are redirected.
+func (h *Handler) Redirects(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		c := h.getCache()
+
+		if target, status, ok := c.GetRedirect(r.URL.Path); ok {
+			if status == http.StatusGone {
+				http.Error(w, "This page has been removed.", http.StatusGone)
+				return
+			}
+			http.Redirect(w, r, withQuery(target, r.URL.RawQuery), status)
+			return
+		}
+
+		if target, ok := c.GetAlias(r.URL.Path); ok {
+			http.Redirect(w, r, withQuery(target, r.URL.RawQuery), http.StatusMovedPermanently)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withQuery carries the query string of the original request over to `target`.
+func withQuery(target, rawQuery string) string {
+	if rawQuery == "" {
+		return target
+	}
+	if strings.Contains(target, "?") {
+		return target + "&" + rawQuery
+	}
+	return target + "?" + rawQuery
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/victhorio/jambe-verte/internal/cache"
+	"github.com/victhorio/jambe-verte/internal/content"
+	"github.com/victhorio/jambe-verte/internal/livereload"
+	"github.com/victhorio/jambe-verte/internal/logger"
+	"github.com/victhorio/jambe-verte/internal/redirects"
+	"github.com/victhorio/jambe-verte/internal/watcher"
+)
+
+// hotReloadDebounce is how long the watcher waits for changes to settle before
+// reporting them, so that saving several files at once triggers a single reload.
+const hotReloadDebounce = 100 * time.Millisecond
+
+// StartHotReload watches content, templates and the Tailwind input stylesheet until
+// `ctx` is cancelled, applying changes through HotReload. Meant for debug mode.
+func (h *Handler) StartHotReload(ctx context.Context) {
+	h.liveReload = livereload.NewBroker()
+	h.cssRebuilds = make(chan struct{}, 1)
+	go h.rebuildCSSLoop(ctx)
+
+	targets := []watcher.Target{
+		{Kind: watcher.Posts, Dir: h.cfg.Content.PostsDir, Pattern: "*.md"},
+		{Kind: watcher.Pages, Dir: h.cfg.Content.PagesDir, Pattern: "*.md"},
+		{Kind: watcher.Templates, Dir: "templates", Pattern: "*.html"},
+		{Kind: watcher.CSS, Dir: "static/css", Pattern: "input.css"},
+	}
+	if rules := h.cfg.Content.Redirects; rules != "" {
+		targets = append(targets, watcher.Target{Kind: watcher.Redirects, Dir: filepath.Dir(rules), Pattern: filepath.Base(rules)})
+	}
+	w := watcher.New(targets, hotReloadDebounce, func(change watcher.Change) {
+		h.HotReload(ctx, change)
+	})
+	go w.Run(ctx)
+}
+
+// HotReload rebuilds only what `change` affects: templates are re-parsed, and only the
+// side of the content (posts or pages) that changed is reloaded from disk, reusing the
+// other from the current snapshot. When anything fails to load, the current state is
+// kept so that a half-written file doesn't take the whole site down.
+func (h *Handler) HotReload(ctx context.Context, change watcher.Change) {
+	start := time.Now()
+	log := logger.Logger.With("paths", change.Paths)
+
+	reloaded := false
+	if change.Has(watcher.Templates) {
+		templates, err := parseTemplates()
+		if err != nil {
+			log.ErrorContext(ctx, "Hot reload failed to parse templates, keeping previous ones", "error", err)
+		} else {
+			h.mu.Lock()
+			h.templates = templates
+			h.mu.Unlock()
+			reloaded = true
+		}
+	}
+
+	if change.Has(watcher.Posts | watcher.Pages | watcher.Templates | watcher.Redirects) {
+		c := h.getCache()
+		next, err := h.reloadContent(c, change)
+		if err != nil {
+			log.ErrorContext(ctx, "Hot reload failed to load content, keeping previous snapshot", "error", err)
+		} else {
+			h.setCache(next)
+			reloaded = true
+		}
+	}
+
+	if reloaded {
+		h.liveReload.Publish(livereload.Reload)
+	}
+
+	// Tailwind scans templates for classes, so template changes need a rebuild as well
+	if change.Has(watcher.CSS | watcher.Templates) {
+		select {
+		case h.cssRebuilds <- struct{}{}:
+		default:
+			// A rebuild is already queued and will pick up this change too
+		}
+	}
+
+	log.InfoContext(ctx, "Hot reloaded changes", "duration", time.Since(start).String())
+}
+
+// reloadContent builds the snapshot that follows `c` after `change`. Template-only
+// changes still need a new snapshot, since the rendered pages in its PageCache are stale.
+// Redirect rules are reloaded with every snapshot.
+func (h *Handler) reloadContent(c *cache.Cache, change watcher.Change) (*cache.Cache, error) {
+	next, _, err := h.loadSnapshot(c, change.Has(watcher.Posts), change.Has(watcher.Pages))
+	if err != nil {
+		return nil, err
+	}
+	if !change.Has(watcher.Templates) {
+		h.carryOver(c, next)
+	}
+	return next, nil
+}
+
+// loadSnapshot builds the snapshot that follows `c`, reloading posts and/or pages from
+// disk. Files that haven't changed since `c` was loaded reuse their parsed posts, and
+// the side that isn't reloaded is reused as a whole.
+func (h *Handler) loadSnapshot(c *cache.Cache, reloadPosts, reloadPages bool) (*cache.Cache, content.Changes, error) {
+	sources := c.GetSources()
+	posts, pages := sources.Posts, sources.Pages
+	var changes content.Changes
+
+	// Both sides are loaded even if the first fails, so that every problem is
+	// reported at once
+	var errs []error
+	if reloadPosts {
+		loaded, postChanges, err := content.Reload(h.cfg.Content.PostsDir, true, h.postLoadOptions(), posts.Files)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load posts: %w", err))
+		}
+		posts = loaded
+		changes = changes.Merge(postChanges)
+	}
+	if reloadPages {
+		loaded, pageChanges, err := content.Reload(h.cfg.Content.PagesDir, false, h.pageLoadOptions(), pages.Files)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load pages: %w", err))
+		}
+		pages = loaded
+		changes = changes.Merge(pageChanges)
+	}
+
+	// Redirect rules are a single small file, so they're simply reloaded every time
+	rules, err := redirects.LoadFile(h.cfg.Content.Redirects, h.cfg.Content.Strict)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to load redirects: %w", err))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, changes, err
+	}
+
+	// Everything else (such as the PageCache budget and stats) carries over
+	sources.Posts, sources.Pages, sources.Redirects = posts, pages, rules
+	next, err := NewSnapshot(sources, h.cfg.Content.Strict)
+	if err != nil {
+		return nil, changes, err
+	}
+	return next, changes, nil
+}
+
+// NewSnapshot builds a snapshot from `sources`, keeping content off the slugs used by
+// other routes (see ReservedSlug). Slug and alias conflicts fail the snapshot when
+// `strict`, and are otherwise logged and left out.
+func NewSnapshot(sources cache.Sources, strict bool) (*cache.Cache, error) {
+	sources.Reserved = ReservedSlug
+	c := cache.NewFromSources(sources)
+
+	conflicts := c.Conflicts()
+	if len(conflicts) > 0 && strict {
+		return nil, fmt.Errorf("%d slug conflict(s): %w", len(conflicts), errors.Join(conflicts...))
+	}
+	for _, conflict := range conflicts {
+		logger.Logger.Warn("Skipping content with a conflicting slug", "error", conflict)
+	}
+	return c, nil
+}
+
+// carryOver copies the rendered pages of `prev` that are still valid for `next`, so
+// that `next` doesn't start cold. Reused posts and pages are the very same *Post, so a
+// post or page route is valid as long as its post is identical. Every other route
+// (listings, tags, feeds, sitemap) is built from all published posts and pages, so
+// those only carry over when none of them changed. Must be called before `next` starts
+// serving requests, and not at all when templates changed.
+func (h *Handler) carryOver(prev, next *cache.Cache) int {
+	sameAggregates := slices.Equal(prev.GetPosts(), next.GetPosts()) &&
+		slices.Equal(prev.GetPages(), next.GetPages())
+
+	copied := next.GetPageCache().CopyFrom(prev.GetPageCache(), func(route string) bool {
+		if slug, ok := strings.CutPrefix(route, "/blog/"); ok {
+			before, _ := prev.GetPost(slug)
+			after, ok := next.GetPost(slug)
+			return ok && before == after
+		}
+		if slug, ok := strings.CutPrefix(route, "/"); ok {
+			if after, ok := next.GetPage(slug); ok {
+				before, _ := prev.GetPage(slug)
+				return before == after
+			}
+		}
+		return sameAggregates
+	})
+
+	logger.Logger.Debug("Carried over rendered pages", "count", copied)
+	return copied
+}
+
+// rebuildCSSLoop runs CSS rebuilds in the background, one at a time. Requests that
+// arrive while a rebuild is running are coalesced into a single follow-up rebuild.
+func (h *Handler) rebuildCSSLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.cssRebuilds:
+			content.RebuildCSS(ctx)
+			h.liveReload.Publish(livereload.CSS)
+		}
+	}
+}
+
+// LiveReload streams reload events to browsers over Server-Sent Events. It must only
+// be routed in debug mode, after StartHotReload.
+func (h *Handler) LiveReload(w http.ResponseWriter, r *http.Request) {
+	h.liveReload.ServeHTTP(w, r)
+}
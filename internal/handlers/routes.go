@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/victhorio/jambe-verte/internal/cache"
+	"github.com/victhorio/jambe-verte/internal/sitemap"
+)
+
+// Routes registers every public route on `r`. Admin and static routes are left to
+// the caller since they depend on how the binary is deployed.
+func (h *Handler) Routes(r chi.Router) {
+	r.Get("/", h.Home)
+	r.Get("/posts", h.ListPosts)
+	r.Get("/posts/page/{n}", h.ListPostsPage)
+	r.Get("/blog/{slug}", h.ShowPost)
+	r.Get("/tag/{tag}", h.PostsByTag)
+	r.Get("/tag/{tag}/page/{n}", h.PostsByTagPage)
+	r.Get("/tag/{tag}/feed.xml", h.TagRSSFeed)
+	r.Get("/tag/{tag}/atom.xml", h.TagAtomFeed)
+	r.Get("/tag/{tag}/feed.json", h.TagJSONFeed)
+	r.Get("/feed.xml", h.RSSFeed)
+	r.Get("/atom.xml", h.AtomFeed)
+	r.Get("/feed.json", h.JSONFeed)
+	r.Get("/sitemap.xml", h.Sitemap)
+	r.Get("/sitemap-{n}.xml", h.SitemapPart)
+	r.Get("/robots.txt", h.Robots)
+	r.Get("/{page}", h.ShowPage)
+
+	// Cached routes are served precompressed (see serveEntry), so only the routes that
+	// render on every request go through the generic compressor
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Compress(5))
+		r.Get("/search", h.Search)
+		r.Get("/search.json", h.SearchJSON)
+		r.Get("/preview/{slug}", h.ShowPreview)
+	})
+}
+
+// reservedSlugs are the top level path segments used by routes other than pages,
+// including the ones registered by the binaries (admin, static files, live reload).
+var reservedSlugs = map[string]bool{
+	"posts": true, "blog": true, "tag": true, "search": true, "search.json": true,
+	"feed.xml": true, "atom.xml": true, "feed.json": true, "sitemap.xml": true,
+	"robots.txt": true, "preview": true, "admin": true, "static": true, "_jv": true,
+	"metrics": true, "healthz": true, "readyz": true, "version": true,
+}
+
+// ReservedSlug reports whether `slug` is taken by a route other than posts and pages,
+// and is thus unavailable to content. See cache.Sources.
+func ReservedSlug(slug string) bool {
+	if reservedSlugs[slug] {
+		return true
+	}
+	// Sitemap parts: /sitemap-1.xml, /sitemap-2.xml...
+	n, ok := strings.CutPrefix(slug, "sitemap-")
+	n, ok2 := strings.CutSuffix(n, ".xml")
+	return ok && ok2
+}
+
+// tagRoute returns the route listing the posts with `tag`, escaped so that tags with
+// spaces or reserved characters (e.g. `#`, `?`) still make a single path segment.
+func tagRoute(tag string) string {
+	return "/tag/" + url.PathEscape(tag)
+}
+
+// KnownRoutes lists every public route that the snapshot `c` can serve, in a stable
+// order. Draft previews are left out since they're only reachable through signed links.
+func (h *Handler) KnownRoutes(c *cache.Cache) []string {
+	size := h.cfg.Pagination.PageSize
+	routes := []string{"/"}
+	for n := 1; n <= totalPages(len(c.GetPosts()), size); n++ {
+		routes = append(routes, pageRoute("/posts", n))
+	}
+
+	for _, post := range c.GetPosts() {
+		routes = append(routes, "/blog/"+post.Slug)
+	}
+	for _, page := range c.GetPages() {
+		routes = append(routes, "/"+page.Slug)
+	}
+	for _, tag := range c.GetTags() {
+		route := tagRoute(tag)
+		for n := 1; n <= totalPages(len(c.GetPostsByTag(tag)), size); n++ {
+			routes = append(routes, pageRoute(route, n))
+		}
+		routes = append(routes,
+			route+"/feed.xml",
+			route+"/atom.xml",
+			route+"/feed.json",
+		)
+	}
+
+	routes = append(routes, "/feed.xml", "/atom.xml", "/feed.json", "/sitemap.xml", "/robots.txt")
+
+	// The base URL doesn't matter here, we only need to know how many parts there are
+	if chunks := sitemap.Split(h.sitemapURLs(c, "")); len(chunks) > 1 {
+		for i := range chunks {
+			routes = append(routes, fmt.Sprintf("/sitemap-%d.xml", i+1))
+		}
+	}
+	return routes
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/victhorio/jambe-verte/internal/cache"
+	"github.com/victhorio/jambe-verte/internal/logger"
+)
+
+type ScheduledPost struct {
+	Slug      string    `json:"slug"`
+	Title     string    `json:"title"`
+	PublishAt time.Time `json:"publishAt"`
+}
+
+// schedulePublishLocked arms a timer that swaps in a rebuilt snapshot once the next
+// scheduled post of `c` comes due, replacing any previously armed timer. Must be
+// called with h.mu held for writing.
+func (h *Handler) schedulePublishLocked(c *cache.Cache) {
+	if h.publishTimer != nil {
+		h.publishTimer.Stop()
+		h.publishTimer = nil
+	}
+
+	next, ok := c.NextPublishAt()
+	if !ok {
+		return
+	}
+
+	scheduled := c.GetScheduled()
+	logger.Logger.Info(
+		"Next scheduled post",
+		"slug", scheduled[0].Slug,
+		"publish_at", next,
+		"in", time.Until(next).Round(time.Second).String(),
+		"scheduled_count", len(scheduled),
+	)
+
+	h.publishTimer = time.AfterFunc(time.Until(next), func() {
+		// A refresh may have swapped the snapshot while we were waiting, in which case
+		// it has already armed its own timer.
+		if current := h.getCache(); current != c {
+			return
+		}
+
+		// The rebuilt snapshot is warmed up without holding the lock, so that requests
+		// keep being served from `c` in the meantime
+		rebuilt := c.Rebuild()
+		h.carryOver(c, rebuilt)
+		h.warmUp(context.Background(), rebuilt)
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.cache != c {
+			return
+		}
+		rebuilt.GetPageCache().Inherit(c.GetPageCache())
+		h.cache = rebuilt
+		logger.Logger.Info(
+			"Published scheduled posts",
+			"published", len(scheduled)-len(rebuilt.GetScheduled()),
+			"posts", len(rebuilt.GetPosts()),
+		)
+		h.schedulePublishLocked(rebuilt)
+	})
+}
+
+// AdminSchedule lists the posts waiting to be published, soonest first.
+func (h *Handler) AdminSchedule(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithRequest(r.Context())
+
+	c := h.getCache()
+
+	scheduled := c.GetScheduled()
+	schedule := make([]ScheduledPost, len(scheduled))
+	for i, post := range scheduled {
+		schedule[i] = ScheduledPost{
+			Slug:      post.Slug,
+			Title:     post.Title,
+			PublishAt: post.PublishAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(schedule); err != nil {
+		log.Error("Failed to write schedule", "error", err)
+	}
+}
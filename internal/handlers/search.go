@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/victhorio/jambe-verte/internal/logger"
+	"github.com/victhorio/jambe-verte/internal/search"
+)
+
+const (
+	maxSearchResults = 50
+	// maxQueryLength bounds the work a single query can cause
+	maxQueryLength = 200
+)
+
+type SearchPageData struct {
+	Query   string
+	Results []search.Result
+}
+
+type SearchResultJSON struct {
+	Slug    string    `json:"slug"`
+	Title   string    `json:"title"`
+	URL     string    `json:"url"`
+	Date    time.Time `json:"date"`
+	Tags    []string  `json:"tags"`
+	Score   float64   `json:"score"`
+	Snippet string    `json:"snippet"`
+}
+
+type SearchResponseJSON struct {
+	Query   string             `json:"query"`
+	Results []SearchResultJSON `json:"results"`
+}
+
+// Search renders the results page for `?q=`. Results depend on arbitrary user input,
+// so they're rendered on every request instead of going into the PageCache.
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	c := h.getCache()
+
+	query := searchQuery(r)
+	data := SearchPageData{
+		Query:   query,
+		Results: c.GetSearchIndex().Search(query, maxSearchResults),
+	}
+
+	h.renderAndCache(w, r, nil, "/search", "search", data)
+}
+
+// SearchJSON is the JSON variant of Search. Snippets are HTML with <mark> highlights.
+func (h *Handler) SearchJSON(w http.ResponseWriter, r *http.Request) {
+	log := logger.WithRequest(r.Context())
+
+	c := h.getCache()
+
+	query := searchQuery(r)
+	results := c.GetSearchIndex().Search(query, maxSearchResults)
+
+	baseURL := h.baseURL(r)
+	resp := SearchResponseJSON{
+		Query:   query,
+		Results: make([]SearchResultJSON, len(results)),
+	}
+	for i, result := range results {
+		resp.Results[i] = SearchResultJSON{
+			Slug:    result.Post.Slug,
+			Title:   result.Post.Title,
+			URL:     baseURL + "/blog/" + result.Post.Slug,
+			Date:    result.Post.Date,
+			Tags:    result.Post.Tags,
+			Score:   result.Score,
+			Snippet: string(result.Snippet),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error("Failed to write search results", "error", err)
+	}
+}
+
+// searchQuery extracts the trimmed `q` parameter, capped at maxQueryLength runes.
+func searchQuery(r *http.Request) string {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if runes := []rune(query); len(runes) > maxQueryLength {
+		query = string(runes[:maxQueryLength])
+	}
+	return query
+}
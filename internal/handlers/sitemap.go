@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/victhorio/jambe-verte/internal/cache"
+	"github.com/victhorio/jambe-verte/internal/sitemap"
+)
+
+const sitemapContentType = "application/xml; charset=utf-8"
+
+// Sitemap serves /sitemap.xml. Small sites get a single urlset; once there are more
+// than sitemap.MaxURLs URLs it becomes an index pointing at /sitemap-{n}.xml files.
+func (h *Handler) Sitemap(w http.ResponseWriter, r *http.Request) {
+	c := h.getCache()
+
+	baseURL := h.baseURL(r)
+	h.generateAndCache(w, r, c.GetPageCache(), "/sitemap.xml", sitemapContentType, "JVE-IHM-XE", func() ([]byte, error) {
+		chunks := sitemap.Split(h.sitemapURLs(c, baseURL))
+		if len(chunks) == 1 {
+			return sitemap.EncodeURLSet(chunks[0])
+		}
+
+		parts := make([]sitemap.URL, len(chunks))
+		for i, chunk := range chunks {
+			parts[i] = sitemap.URL{
+				Loc:     fmt.Sprintf("%s/sitemap-%d.xml", baseURL, i+1),
+				LastMod: sitemap.LastMod(chunk),
+			}
+		}
+		return sitemap.EncodeIndex(parts)
+	})
+}
+
+// SitemapPart serves one of the /sitemap-{n}.xml files listed by the sitemap index.
+func (h *Handler) SitemapPart(w http.ResponseWriter, r *http.Request) {
+	c := h.getCache()
+
+	n, err := strconv.Atoi(chi.URLParam(r, "n"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Parts only exist when the sitemap had to be split into an index
+	baseURL := h.baseURL(r)
+	chunks := sitemap.Split(h.sitemapURLs(c, baseURL))
+	if len(chunks) == 1 || n < 1 || n > len(chunks) {
+		http.NotFound(w, r)
+		return
+	}
+
+	route := fmt.Sprintf("/sitemap-%d.xml", n)
+	h.generateAndCache(w, r, c.GetPageCache(), route, sitemapContentType, "JVE-IHM-XE", func() ([]byte, error) {
+		return sitemap.EncodeURLSet(chunks[n-1])
+	})
+}
+
+// Robots serves /robots.txt from the robots section of the config.
+func (h *Handler) Robots(w http.ResponseWriter, r *http.Request) {
+	c := h.getCache()
+
+	baseURL := h.baseURL(r)
+	h.generateAndCache(w, r, c.GetPageCache(), "/robots.txt", "text/plain; charset=utf-8", "JVE-IHM-RE", func() ([]byte, error) {
+		var b strings.Builder
+		b.WriteString("User-agent: *\n")
+		if len(h.cfg.Robots.Disallow) == 0 {
+			// An empty Disallow explicitly allows everything
+			b.WriteString("Disallow:\n")
+		}
+		for _, path := range h.cfg.Robots.Disallow {
+			fmt.Fprintf(&b, "Disallow: %s\n", path)
+		}
+		if extra := strings.TrimSpace(h.cfg.Robots.Extra); extra != "" {
+			fmt.Fprintf(&b, "\n%s\n", extra)
+		}
+		fmt.Fprintf(&b, "\nSitemap: %s/sitemap.xml\n", baseURL)
+		return []byte(b.String()), nil
+	})
+}
+
+// sitemapURLs lists every public route of the snapshot: the home page, the post
+// listing, each post, page and tag. Posts use their updated timestamp as lastmod,
+// while pages fall back to the modification time of their source file.
+func (h *Handler) sitemapURLs(c *cache.Cache, baseURL string) []sitemap.URL {
+	posts := c.GetPosts()
+
+	var latest time.Time
+	for _, post := range posts {
+		if post.Updated.After(latest) {
+			latest = post.Updated
+		}
+	}
+
+	urls := []sitemap.URL{
+		sitemap.NewURL(baseURL+"/", latest),
+		sitemap.NewURL(baseURL+"/posts", latest),
+	}
+	for _, post := range posts {
+		urls = append(urls, sitemap.NewURL(baseURL+"/blog/"+post.Slug, post.Updated))
+	}
+	for _, page := range c.GetPages() {
+		urls = append(urls, sitemap.NewURL(baseURL+"/"+page.Slug, page.ModTime))
+	}
+	for _, tag := range c.GetTags() {
+		var tagLatest time.Time
+		for _, post := range c.GetPostsByTag(tag) {
+			if post.Updated.After(tagLatest) {
+				tagLatest = post.Updated
+			}
+		}
+		urls = append(urls, sitemap.NewURL(baseURL+tagRoute(tag), tagLatest))
+	}
+	return urls
+}
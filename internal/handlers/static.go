@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// sidecarEncodings maps the content encodings of precompressed static files to the
+// extension of their sidecar, in order of preference.
+var sidecarEncodings = []struct{ encoding, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// compressEncodings are the content encodings that middleware.Compress produces.
+var compressEncodings = []string{"gzip", "deflate"}
+
+// StaticFiles serves the files under `dir` like http.FileServer, adding an ETag so
+// that conditional requests can be answered with 304 Not Modified. The ETag is derived
+// from the file's size and modification time, which is enough for files that only
+// change on deploys and saves us from hashing them on every request.
+//
+// Files with precompressed sidecars next to them (`output.css.br`, `output.css.gz`)
+// are served from the sidecar to clients that accept its encoding. Sidecars older than
+// their file are ignored, since they'd serve stale content. Other files are compressed
+// on the fly, under a weak ETag since the compressed bytes aren't the file's.
+func StaticFiles(dir string) http.Handler {
+	fileServer := http.FileServer(http.Dir(dir))
+	compressed := middleware.Compress(5)(fileServer)
+	serveFile := func(w http.ResponseWriter, r *http.Request, info os.FileInfo) {
+		etag := staticETag(info, "")
+		if negotiateEncoding(r, compressEncodings) != "" {
+			etag = "W/" + etag
+		}
+		w.Header().Set("ETag", etag)
+		compressed.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		info, err := os.Stat(name)
+		if err != nil || !info.Mode().IsRegular() {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		// Assets aren't fingerprinted, so they need revalidating like pages do
+		w.Header().Set("Cache-Control", pageCacheControl)
+
+		var offered []string
+		sidecars := make(map[string]os.FileInfo)
+		for _, s := range sidecarEncodings {
+			sidecar, err := os.Stat(name + s.ext)
+			if err == nil && sidecar.Mode().IsRegular() && !sidecar.ModTime().Before(info.ModTime()) {
+				offered = append(offered, s.encoding)
+				sidecars[s.encoding] = sidecar
+			}
+		}
+		if len(offered) > 0 {
+			w.Header().Add("Vary", "Accept-Encoding")
+		}
+
+		encoding := negotiateEncoding(r, offered)
+		if encoding == "" {
+			serveFile(w, r, info)
+			return
+		}
+
+		sidecar := sidecars[encoding]
+		f, err := os.Open(filepath.Join(filepath.Dir(name), sidecar.Name()))
+		if err != nil {
+			// The sidecar went away since we looked, the original will do
+			serveFile(w, r, info)
+			return
+		}
+		defer f.Close()
+
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Set("ETag", staticETag(sidecar, encoding))
+		http.ServeContent(w, r, "", sidecar.ModTime(), f)
+	})
+}
+
+// staticETag derives the ETag of a static file, or of its sidecar for `encoding`.
+func staticETag(info os.FileInfo, encoding string) string {
+	if encoding == "" {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+	}
+	return fmt.Sprintf(`"%x-%x-%s"`, info.ModTime().UnixNano(), info.Size(), encoding)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/victhorio/jambe-verte/internal/cache"
+	"github.com/victhorio/jambe-verte/internal/logger"
+)
+
+// Start warms up the snapshot the handler was created with and then marks the handler
+// ready, see Ready. It's meant to be called once the server is already listening, and
+// never marks the handler ready once `ctx` is done, since that means it's shutting down.
+func (h *Handler) Start(ctx context.Context) {
+	c := h.getCache()
+	h.warmUp(ctx, c)
+	if ctx.Err() != nil {
+		logger.Logger.Info("Stopped starting up before being ready", "error", ctx.Err())
+		return
+	}
+	h.ready.Store(true)
+	logger.Logger.InfoContext(ctx, "Ready to serve requests")
+}
+
+// Ready reports whether the initial warm-up is done, see Start.
+func (h *Handler) Ready() bool {
+	return h.ready.Load()
+}
+
+// warmUp renders every route of KnownRoutes into the PageCache of `next`, so that it
+// starts serving warm. It must be called before `next` is published with setCache, and
+// after carryOver so that carried over routes aren't rendered again. Does nothing in
+// debug mode, where nothing is cached, or when disabled in the config. Returns how many
+// routes were rendered or already cached.
+func (h *Handler) warmUp(ctx context.Context, next *cache.Cache) int {
+	cfg := h.cfg.Cache.WarmUp
+	if h.debugMode || !cfg.Enabled {
+		return 0
+	}
+	start := time.Now()
+
+	// Renders that are identical to the current snapshot's must keep their validators,
+	// which setCache would only pass on once it's too late
+	if current := h.getCache(); current != nil && current != next {
+		next.GetPageCache().Inherit(current.GetPageCache())
+	}
+
+	var routes []string
+	for _, route := range h.KnownRoutes(next) {
+		// Routes with an extension (feeds, sitemaps, robots.txt) embed absolute URLs,
+		// and aren't cached at all unless the base URL is pinned, see generateAndCache
+		if h.cfg.Site.BaseURL == "" && path.Ext(route) != "" {
+			continue
+		}
+		routes = append(routes, route)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	// When warming up on behalf of a request (e.g. AdminRefresh), its routing state
+	// must not leak into the routing of the warm-up requests
+	ctx = context.WithValue(ctx, chi.RouteCtxKey, nil)
+
+	// The routes are served by a handler pinned to `next`, since it isn't published yet
+	r := chi.NewRouter()
+	h.pinned(next).Routes(r)
+
+	var warmed atomic.Int64
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cfg.Concurrency)
+dispatch:
+	for _, route := range routes {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				// A route that panics must only stay cold, not take the server down
+				if p := recover(); p != nil {
+					logger.Logger.ErrorContext(ctx, "Panic while warming up route", "route", route, "panic", p)
+				}
+				<-sem
+				wg.Done()
+			}()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.cfg.Site.BaseURL+route, nil)
+			if err != nil {
+				logger.Logger.WarnContext(ctx, "Failed to build warm-up request", "route", route, "error", err)
+				return
+			}
+			rec := &statusRecorder{header: make(http.Header), status: http.StatusOK}
+			r.ServeHTTP(rec, req)
+			if rec.status != http.StatusOK {
+				logger.Logger.WarnContext(ctx, "Failed to warm up route", "route", route, "status", rec.status)
+				return
+			}
+			warmed.Add(1)
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		logger.Logger.Warn("Warm-up stopped early, leaving the remaining routes cold", "warmed", warmed.Load(), "routes", len(routes), "timeout", cfg.Timeout.String())
+	} else {
+		logger.Logger.Info("Warmed up routes", "warmed", warmed.Load(), "routes", len(routes), "duration", time.Since(start).String())
+	}
+	return int(warmed.Load())
+}
+
+// pinned returns a handler that serves the snapshot `c` rather than the current one,
+// sharing everything else with `h`. It's only meant for rendering routes into `c`, so
+// its lookups don't count towards the PageCache stats.
+func (h *Handler) pinned(c *cache.Cache) *Handler {
+	h.mu.RLock()
+	templates := h.templates
+	h.mu.RUnlock()
+
+	return &Handler{
+		cache:         c,
+		cfg:           h.cfg,
+		debugMode:     h.debugMode,
+		previewSigner: h.previewSigner,
+		templates:     templates,
+		warming:       true,
+	}
+}
+
+// statusRecorder is the http.ResponseWriter of warm-up requests, which only need their
+// status since the rendered output ends up in the PageCache.
+type statusRecorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
@@ -0,0 +1,126 @@
+package listener
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/victhorio/jambe-verte/internal/logger"
+)
+
+// unixPrefix marks addresses that are Unix domain socket paths, e.g. `unix:/run/jv.sock`.
+const unixPrefix = "unix:"
+
+// listenFDsStart is the first file descriptor passed by systemd socket activation.
+const listenFDsStart = 3
+
+// Listen returns the listener the server should accept connections on. A socket passed
+// by systemd socket activation takes precedence, and otherwise `address` is either a
+// TCP address (`:8080`, `127.0.0.1:8080`) or a Unix socket path prefixed by `unix:`.
+func Listen(address string) (net.Listener, error) {
+	ln, err := systemdListener()
+	if err != nil {
+		return nil, err
+	}
+	if ln != nil {
+		logger.Logger.Info("Using listener passed by systemd, ignoring the configured address", "address", ln.Addr().String())
+		return ln, nil
+	}
+
+	if path, ok := strings.CutPrefix(address, unixPrefix); ok {
+		return listenUnix(path)
+	}
+	return net.Listen("tcp", address)
+}
+
+// systemdListener returns the socket passed by systemd socket activation, or nil when
+// the process wasn't socket activated. See sd_listen_fds(3).
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+
+	// The variables are meant for this process only, not for anything it spawns
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if fds > 1 {
+		logger.Logger.Warn("systemd passed several sockets, only the first one is used", "count", fds)
+	}
+
+	f := os.NewFile(listenFDsStart, "LISTEN_FD_3")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use the socket passed by systemd: %w", err)
+	}
+	return ln, nil
+}
+
+// listenUnix listens on the Unix socket at `path`, replacing a socket left behind by
+// a previous run that didn't shut down cleanly. Other files are never replaced.
+func listenUnix(path string) (net.Listener, error) {
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil && info.Mode().Type() == fs.ModeSocket:
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	case err == nil:
+		return nil, fmt.Errorf("failed to listen on %s: file exists and isn't a socket", path)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+
+	return net.Listen("unix", path)
+}
+
+// Certificates serves a TLS certificate that can be reloaded from disk at any time,
+// e.g. after a renewal, without restarting the server.
+type Certificates struct {
+	certFile string
+	keyFile  string
+	current  atomic.Pointer[tls.Certificate]
+}
+
+// LoadCertificates loads the certificate and key at the given paths, both PEM encoded.
+func LoadCertificates(certFile, keyFile string) (*Certificates, error) {
+	c := &Certificates{certFile: certFile, keyFile: keyFile}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload loads the certificate and key again. On failure the previous certificate
+// keeps being served.
+func (c *Certificates) Reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", c.certFile, err)
+	}
+	c.current.Store(&cert)
+	return nil
+}
+
+// TLSConfig returns a TLS configuration serving the current certificate.
+func (c *Certificates) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.current.Load(), nil
+		},
+	}
+}
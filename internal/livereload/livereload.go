@@ -0,0 +1,113 @@
+package livereload
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/victhorio/jambe-verte/internal/logger"
+)
+
+// Event is what browsers are told to do after a change.
+type Event string
+
+const (
+	// Reload asks the browser for a full page reload.
+	Reload Event = "reload"
+	// CSS asks the browser to swap its stylesheets in place, keeping scroll position.
+	CSS Event = "css"
+)
+
+const (
+	heartbeatInterval = 15 * time.Second
+	// maxStreamDuration ends streams before the router's request timeout does, so the
+	// browser can reconnect cleanly instead of getting a timeout mid-stream.
+	maxStreamDuration = 45 * time.Second
+	// retryMillis is how soon browsers reconnect once a stream ends.
+	retryMillis = 1000
+)
+
+// Broker fans events out to every connected browser over Server-Sent Events.
+type Broker struct {
+	mu      sync.Mutex
+	clients map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{clients: make(map[chan Event]struct{})}
+}
+
+// Publish sends `event` to every connected browser. Browsers that are too slow to keep
+// up miss the event rather than blocking the publisher.
+func (b *Broker) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.clients {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	logger.Logger.Debug("Published live reload event", "event", event, "clients", len(b.clients))
+}
+
+func (b *Broker) subscribe() chan Event {
+	ch := make(chan Event, 4)
+	b.mu.Lock()
+	b.clients[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *Broker) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	delete(b.clients, ch)
+	b.mu.Unlock()
+}
+
+// ServeHTTP streams events to a single browser until it disconnects or the stream
+// reaches maxStreamDuration.
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	// The server's write timeout is meant for regular responses, not for streams
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logger.WithRequest(r.Context()).Warn("Could not lift write deadline for live reload stream", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+	if err := rc.Flush(); err != nil {
+		logger.WithRequest(r.Context()).Error("Live reload requires a flushable response writer", "error", err)
+		return
+	}
+
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	deadline := time.NewTimer(maxStreamDuration)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline.C:
+			return
+		case <-heartbeat.C:
+			// Comment lines keep idle connections from being closed by proxies
+			fmt.Fprint(w, ": ping\n\n")
+		case event := <-ch:
+			fmt.Fprintf(w, "event: %s\ndata: {}\n\n", event)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
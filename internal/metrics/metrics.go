@@ -0,0 +1,308 @@
+// Package metrics implements the few Prometheus metric types the server needs and
+// serves them in the Prometheus text exposition format, without pulling in the full
+// client library.
+//
+// Metrics are registered on a Registry when they're created: on Default for package
+// level variables of the package they instrument, or on one passed in by the caller
+// for metrics that belong to a value (e.g. a handler) rather than to the process. Like
+// with the Prometheus client, creating two metrics with the same name on a registry,
+// or passing the wrong number of label values, is a programming error and panics.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of latency histograms.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry of the process, which the server exposes.
+var Default = NewRegistry()
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry is a set of metrics that can be exposed together.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry returns an empty registry, e.g. to keep tests from sharing Default.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.metrics[name] = m
+}
+
+// Write writes every metric of the registry to `w`, sorted by name.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	metrics := make([]metric, len(names))
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry to Prometheus scrapers.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// desc is what every metric type has in common.
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) writeHeader(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.ReplaceAll(d.help, "\n", " "))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, typ)
+}
+
+// key identifies a series by its label values.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// writeSample writes a single sample line, adding `extra` after the metric's labels.
+func (d desc) writeSample(w *bufio.Writer, suffix string, values []string, extra string, value float64) {
+	w.WriteString(d.name)
+	w.WriteString(suffix)
+
+	var pairs []string
+	for i, label := range d.labels {
+		pairs = append(pairs, label+`="`+escapeLabel(values[i])+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedSeries returns the series of `m` sorted by their label values, so that the
+// output is stable across scrapes.
+func sortedSeries[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// NewCounterVec registers a counter on Default, partitioned by `labels`.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// NewCounterVec registers a counter on `r`, partitioned by `labels`.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name, help, labels}, series: make(map[string]*counterSeries)}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the series with the given label values.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds `v`, which must not be negative, to the series with the given label values.
+func (c *CounterVec) Add(v float64, values ...string) {
+	key := c.key(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: slices.Clone(values)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeHeader(w, "counter")
+	for _, key := range sortedSeries(c.series) {
+		s := c.series[key]
+		c.writeSample(w, "", s.values, "", s.value)
+	}
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	// counts holds the observations per bucket, not cumulative
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram on Default with the given bucket upper bounds,
+// partitioned by `labels`.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// NewHistogramVec registers a histogram on `r` with the given bucket upper bounds,
+// partitioned by `labels`.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{name, help, labels},
+		buckets: slices.Sorted(slices.Values(buckets)),
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(name, h)
+	return h
+}
+
+// Observe records `v` on the series with the given label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := h.key(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: slices.Clone(values), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w, "histogram")
+	for _, key := range sortedSeries(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			h.writeSample(w, "_bucket", s.values, `le="`+formatFloat(bound)+`"`, float64(cumulative))
+		}
+		h.writeSample(w, "_bucket", s.values, `le="+Inf"`, float64(s.count))
+		h.writeSample(w, "_sum", s.values, "", s.sum)
+		h.writeSample(w, "_count", s.values, "", float64(s.count))
+	}
+}
+
+// funcMetric is a metric without labels whose value is read when it's scraped.
+type funcMetric struct {
+	desc
+	typ string
+	fn  func() float64
+}
+
+// NewGaugeFunc registers a gauge on Default whose value is `fn`'s at scrape time.
+func NewGaugeFunc(name, help string, fn func() float64) {
+	Default.NewGaugeFunc(name, help, fn)
+}
+
+// NewGaugeFunc registers a gauge on `r` whose value is `fn`'s at scrape time.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{desc: desc{name: name, help: help}, typ: "gauge", fn: fn})
+}
+
+// NewCounterFunc registers a counter on Default whose value is `fn`'s at scrape time,
+// for counters that are already kept elsewhere. `fn` must never decrease.
+func NewCounterFunc(name, help string, fn func() float64) {
+	Default.NewCounterFunc(name, help, fn)
+}
+
+// NewCounterFunc registers a counter on `r` whose value is `fn`'s at scrape time, for
+// counters that are already kept elsewhere. `fn` must never decrease.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{desc: desc{name: name, help: help}, typ: "counter", fn: fn})
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	m.writeHeader(w, m.typ)
+	m.writeSample(w, "", nil, "", m.fn())
+}
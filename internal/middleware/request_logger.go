@@ -2,28 +2,60 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/victhorio/jambe-verte/internal/logger"
+	"github.com/victhorio/jambe-verte/internal/metrics"
 )
 
 const slowRequestThreshold = time.Second
 
-func RequestLogger(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip logging for static files
-		if strings.HasPrefix(r.URL.Path, "/static/") {
-			next.ServeHTTP(w, r)
-			return
-		}
+// requestMetrics are the metrics RequestLogger records on its registry.
+type requestMetrics struct {
+	total    *metrics.CounterVec
+	duration *metrics.HistogramVec
+}
+
+// RequestLogger returns a middleware that logs every request but static files and
+// probes, and records request metrics for all of them on `reg`. Metrics are labeled by
+// route pattern rather than path, so that their cardinality doesn't grow with the
+// content or with crawlers making up URLs. It must be called at most once per registry.
+func RequestLogger(reg *metrics.Registry) func(http.Handler) http.Handler {
+	m := &requestMetrics{
+		total: reg.NewCounterVec(
+			"jv_http_requests_total",
+			"HTTP requests by method, route pattern and status.",
+			"method", "route", "status",
+		),
+		duration: reg.NewHistogramVec(
+			"jv_http_request_duration_seconds",
+			"HTTP request latency by route pattern and status.",
+			metrics.DefaultBuckets, "route", "status",
+		),
+	}
+	return func(next http.Handler) http.Handler {
+		return m.logRequests(next)
+	}
+}
 
+func (m *requestMetrics) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 		start := time.Now()
 
 		defer func() {
 			duration := time.Since(start)
+			m.observe(r, ww.Status(), duration)
+
+			// Skip logging for static files and probes, which would drown everything else
+			if strings.HasPrefix(r.URL.Path, "/static/") || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+				return
+			}
+
 			log := logger.WithRequest(r.Context())
 			attrs := []any{
 				"method", r.Method,
@@ -48,3 +80,33 @@ func RequestLogger(next http.Handler) http.Handler {
 		next.ServeHTTP(ww, r)
 	})
 }
+
+func (m *requestMetrics) observe(r *http.Request, status int, duration time.Duration) {
+	// Requests that no route matches have no pattern: the ones answered by the NotFound
+	// handler (including redirect rules and aliases for such paths) and the ones using a
+	// method the matched path doesn't allow. Redirects of a path that some route matches,
+	// e.g. `/{page}`, are labeled with that route's pattern.
+	route := "unmatched"
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		route = rctx.RoutePattern()
+	}
+	// Handlers that never write anything respond with 200
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	statusLabel := strconv.Itoa(status)
+	m.total.Inc(methodLabel(r.Method), route, statusLabel)
+	m.duration.Observe(duration.Seconds(), route, statusLabel)
+}
+
+// methodLabel maps `method` to one of the standard methods, so that clients can't grow
+// the cardinality of request metrics by making up methods.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
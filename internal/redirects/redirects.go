@@ -0,0 +1,187 @@
+package redirects
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/victhorio/jambe-verte/internal/content"
+	"github.com/victhorio/jambe-verte/internal/logger"
+)
+
+// Rule sends requests for From elsewhere, or answers them with 410 Gone.
+//
+// Prefix rules (written with a trailing `*`) match every path starting with From. When
+// their target also ends with `*`, the rest of the path is carried over to it, so that
+// `/2019/* /blog/* 301` sends /2019/hello to /blog/hello.
+type Rule struct {
+	From   string
+	To     string
+	Status int
+	Prefix bool
+	// Line is where the rule was written in the rules file.
+	Line int
+}
+
+// Rules is a parsed rules file. Exact rules take precedence over prefix rules, and
+// longer prefixes over shorter ones. The zero value has no rules.
+type Rules struct {
+	exact  map[string]Rule
+	prefix []Rule
+}
+
+// LoadFile reads the rules at `path`. A missing file simply means there are no rules.
+// Like content files, invalid rules are skipped with a warning, or fail the whole file
+// when `strict`, with every problem reported as a content.FileError.
+func LoadFile(path string, strict bool) (*Rules, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Rules{}, nil
+	}
+	if err != nil {
+		return nil, &content.FileError{Path: path, Err: fmt.Errorf("failed to read: %w", err)}
+	}
+	defer f.Close()
+
+	rules, err := Parse(f, path)
+	if err != nil {
+		if strict {
+			return nil, err
+		}
+		logger.Logger.Warn("Skipping invalid redirect rules", "path", path, "error", err)
+	}
+	logger.Logger.Info("Loaded redirect rules", "path", path, "count", rules.Len())
+	return rules, nil
+}
+
+// Parse reads rules from `r`, one per line, as `from [to] status`. Blank lines and
+// lines starting with # are ignored, and a missing status defaults to 301. Invalid
+// lines are skipped and reported in the returned error, along with the valid rules.
+func Parse(r io.Reader, path string) (*Rules, error) {
+	rules := &Rules{exact: make(map[string]Rule)}
+	var errs []error
+	seen := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := parseRule(strings.Fields(line))
+		if err == nil {
+			if first, ok := seen[rule.From]; ok {
+				err = fmt.Errorf("duplicate rule for %q, first defined on line %d", rule.From, first)
+			}
+		}
+		if err != nil {
+			errs = append(errs, &content.FileError{Path: path, Line: n, Err: err})
+			continue
+		}
+
+		rule.Line = n
+		seen[rule.From] = n
+		if rule.Prefix {
+			rules.prefix = append(rules.prefix, rule)
+		} else {
+			rules.exact[rule.From] = rule
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, &content.FileError{Path: path, Err: fmt.Errorf("failed to read: %w", err)})
+	}
+
+	slices.SortStableFunc(rules.prefix, func(a, b Rule) int {
+		return len(b.From) - len(a.From)
+	})
+	return rules, errors.Join(errs...)
+}
+
+func parseRule(fields []string) (Rule, error) {
+	rule := Rule{Status: http.StatusMovedPermanently}
+
+	// The status is optional and always last
+	if len(fields) > 1 {
+		if status, err := strconv.Atoi(fields[len(fields)-1]); err == nil {
+			rule.Status = status
+			fields = fields[:len(fields)-1]
+		}
+	}
+
+	switch len(fields) {
+	case 1:
+		rule.From = fields[0]
+	case 2:
+		rule.From, rule.To = fields[0], fields[1]
+	default:
+		return Rule{}, errors.New("expected `from [to] [status]`")
+	}
+
+	rule.From, rule.Prefix = strings.CutSuffix(rule.From, "*")
+	if !strings.HasPrefix(rule.From, "/") {
+		return Rule{}, fmt.Errorf("invalid source %q: must start with /", rule.From)
+	}
+
+	switch rule.Status {
+	case http.StatusMovedPermanently, http.StatusFound:
+		if rule.To == "" {
+			return Rule{}, fmt.Errorf("missing target for a %d redirect", rule.Status)
+		}
+		if err := validateTarget(rule); err != nil {
+			return Rule{}, err
+		}
+	case http.StatusGone:
+		if rule.To != "" {
+			return Rule{}, errors.New("410 rules don't take a target")
+		}
+	default:
+		return Rule{}, fmt.Errorf("unsupported status %d: expected 301, 302 or 410", rule.Status)
+	}
+	return rule, nil
+}
+
+func validateTarget(rule Rule) error {
+	if strings.HasSuffix(rule.To, "*") && !rule.Prefix {
+		return fmt.Errorf("invalid target %q: only prefix rules can carry a * over", rule.To)
+	}
+	if strings.HasPrefix(rule.To, "/") {
+		return nil
+	}
+	u, err := url.Parse(strings.TrimSuffix(rule.To, "*"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid target %q: expected a path or an absolute http(s) URL", rule.To)
+	}
+	return nil
+}
+
+// Len returns how many rules there are.
+func (r *Rules) Len() int {
+	return len(r.exact) + len(r.prefix)
+}
+
+// Match returns the rule for `path`, with its target resolved for this path. Targets
+// are empty for 410 rules.
+func (r *Rules) Match(path string) (target string, status int, ok bool) {
+	if rule, ok := r.exact[path]; ok {
+		return rule.To, rule.Status, true
+	}
+	for _, rule := range r.prefix {
+		rest, ok := strings.CutPrefix(path, rule.From)
+		if !ok {
+			continue
+		}
+		if to, ok := strings.CutSuffix(rule.To, "*"); ok {
+			return to + rest, rule.Status, true
+		}
+		return rule.To, rule.Status, true
+	}
+	return "", 0, false
+}
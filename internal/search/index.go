@@ -0,0 +1,207 @@
+package search
+
+import (
+	"html/template"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/victhorio/jambe-verte/internal/content"
+)
+
+// Relative weight of a term occurrence depending on the field it appears in.
+const (
+	titleWeight       = 5.0
+	tagWeight         = 4.0
+	descriptionWeight = 2.0
+	bodyWeight        = 1.0
+
+	// maxBodyOccurrences caps how much a single term repeated in the body can count,
+	// so long posts don't drown out short ones that mention it in the title.
+	maxBodyOccurrences = 10
+
+	snippetLength  = 200
+	snippetLeadIn  = 60
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+)
+
+// Index is an in-memory inverted index over posts. Like the cache that owns it, it's
+// immutable once built and safe for concurrent use.
+type Index struct {
+	docs     []document
+	postings map[string][]posting
+}
+
+type document struct {
+	post *content.Post
+	// body is the plain text of the post, kept around to build snippets
+	body string
+}
+
+type posting struct {
+	doc    int
+	weight float64
+}
+
+type Result struct {
+	Post  *content.Post
+	Score float64
+	// Title and Snippet are HTML-escaped, with matched words wrapped in <mark>.
+	Title   template.HTML
+	Snippet template.HTML
+}
+
+// New indexes the title, description, tags and body text of `posts`.
+func New(posts []*content.Post) *Index {
+	idx := &Index{
+		docs:     make([]document, len(posts)),
+		postings: make(map[string][]posting),
+	}
+
+	for i, post := range posts {
+		body := stripHTML(string(post.HTML))
+		idx.docs[i] = document{post: post, body: body}
+
+		weights := make(map[string]float64)
+		bodyCounts := make(map[string]int)
+		for _, tok := range tokenize(post.Title) {
+			weights[tok.term] += titleWeight
+		}
+		for _, tag := range post.Tags {
+			for _, tok := range tokenize(tag) {
+				weights[tok.term] += tagWeight
+			}
+		}
+		for _, tok := range tokenize(post.Description) {
+			weights[tok.term] += descriptionWeight
+		}
+		for _, tok := range tokenize(body) {
+			if bodyCounts[tok.term] < maxBodyOccurrences {
+				bodyCounts[tok.term]++
+				weights[tok.term] += bodyWeight
+			}
+		}
+
+		for term, weight := range weights {
+			idx.postings[term] = append(idx.postings[term], posting{doc: i, weight: weight})
+		}
+	}
+	return idx
+}
+
+// Search returns up to `limit` posts containing every term of `query`, best first.
+// Terms are weighted by field and by how rare they are across all posts.
+func (idx *Index) Search(query string, limit int) []Result {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	scores := make(map[int]float64)
+	matches := make(map[int]int)
+	for _, term := range terms {
+		postings := idx.postings[term]
+		idf := math.Log(1 + float64(len(idx.docs))/float64(max(len(postings), 1)))
+		for _, p := range postings {
+			scores[p.doc] += p.weight * idf
+			matches[p.doc]++
+		}
+	}
+
+	var docs []int
+	for doc, n := range matches {
+		if n == len(terms) {
+			docs = append(docs, doc)
+		}
+	}
+
+	// Ties are broken by recency and then slug so that results are deterministic
+	slices.SortFunc(docs, func(a, b int) int {
+		if scores[a] != scores[b] {
+			if scores[a] > scores[b] {
+				return -1
+			}
+			return 1
+		}
+		pa, pb := idx.docs[a].post, idx.docs[b].post
+		if c := pb.Date.Compare(pa.Date); c != 0 {
+			return c
+		}
+		return strings.Compare(pa.Slug, pb.Slug)
+	})
+	if limit > 0 && len(docs) > limit {
+		docs = docs[:limit]
+	}
+
+	termSet := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		termSet[term] = true
+	}
+
+	results := make([]Result, len(docs))
+	for i, d := range docs {
+		doc := idx.docs[d]
+		results[i] = Result{
+			Post:    doc.post,
+			Score:   scores[d],
+			Title:   highlight(doc.post.Title, termSet),
+			Snippet: snippet(doc, termSet),
+		}
+	}
+	return results
+}
+
+// snippet picks a window of the body around the first matched term, falling back to
+// the description (or the start of the body) when only other fields matched.
+func snippet(doc document, terms map[string]bool) template.HTML {
+	for _, tok := range tokenize(doc.body) {
+		if !terms[tok.term] {
+			continue
+		}
+
+		start := 0
+		if tok.start > snippetLeadIn {
+			// Start at a word boundary so the snippet doesn't open mid-word
+			start = strings.LastIndexByte(doc.body[:tok.start-snippetLeadIn], ' ') + 1
+		}
+		text := truncate(doc.body[start:], snippetLength)
+
+		var b strings.Builder
+		if start > 0 {
+			b.WriteString("…")
+		}
+		b.WriteString(string(highlight(text, terms)))
+		if start+len(text) < len(doc.body) {
+			b.WriteString("…")
+		}
+		return template.HTML(b.String())
+	}
+
+	if doc.post.Description != "" {
+		return highlight(doc.post.Description, terms)
+	}
+	text := truncate(doc.body, snippetLength)
+	if len(text) < len(doc.body) {
+		return highlight(text, terms) + "…"
+	}
+	return highlight(text, terms)
+}
+
+// highlight HTML-escapes `text`, wrapping the words whose terms are in `terms` in <mark>.
+func highlight(text string, terms map[string]bool) template.HTML {
+	var b strings.Builder
+	last := 0
+	for _, tok := range tokenize(text) {
+		if !terms[tok.term] {
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(text[last:tok.start]))
+		b.WriteString(highlightStart)
+		b.WriteString(template.HTMLEscapeString(text[tok.start:tok.end]))
+		b.WriteString(highlightEnd)
+		last = tok.end
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+	return template.HTML(b.String())
+}
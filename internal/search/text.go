@@ -0,0 +1,105 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/victhorio/jambe-verte/internal/fold"
+)
+
+// token is a normalized term along with the byte offsets of the original word.
+type token struct {
+	term       string
+	start, end int
+}
+
+// normalize lowercases `word` and folds its accents. There's deliberately no stemming:
+// it would need to know which of the two languages each post is written in.
+func normalize(word string) string {
+	return fold.String(word)
+}
+
+// tokenize splits `text` into words made of letters and digits, returning their
+// normalized terms and where each word sits in `text`.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWordRune && start < 0:
+			start = i
+		case !isWordRune && start >= 0:
+			tokens = append(tokens, token{term: normalize(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: normalize(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// queryTerms returns the unique normalized terms of a query, in order of appearance.
+func queryTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, tok := range tokenize(query) {
+		if !seen[tok.term] {
+			seen[tok.term] = true
+			terms = append(terms, tok.term)
+		}
+	}
+	return terms
+}
+
+// inlineTags don't break words when stripped, unlike block-level tags such as <p>.
+var inlineTags = map[string]bool{
+	"a": true, "abbr": true, "b": true, "code": true, "del": true, "em": true, "i": true,
+	"kbd": true, "mark": true, "s": true, "span": true, "strong": true, "sub": true, "sup": true,
+}
+
+// stripHTML reduces rendered post HTML to plain text with collapsed whitespace.
+// Block-level tags are replaced by spaces so that adjacent blocks don't merge into
+// a single word, while inline tags are dropped outright.
+func stripHTML(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for {
+		open := strings.IndexByte(s, '<')
+		if open < 0 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:open])
+
+		end := strings.IndexByte(s[open:], '>')
+		if end < 0 {
+			break
+		}
+		tag := s[open+1 : open+end]
+		s = s[open+end+1:]
+
+		name := strings.TrimPrefix(tag, "/")
+		if i := strings.IndexAny(name, " \t\n/"); i >= 0 {
+			name = name[:i]
+		}
+		if !inlineTags[strings.ToLower(name)] {
+			b.WriteByte(' ')
+		}
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(b.String())), " ")
+}
+
+// truncate cuts `s` to at most `n` bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
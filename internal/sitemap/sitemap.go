@@ -0,0 +1,85 @@
+package sitemap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+// MaxURLs is the protocol limit on the number of URLs in a single sitemap file.
+// Sites with more URLs must be split into several files behind a sitemap index.
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// URL is a single sitemap entry. Loc must be absolute.
+type URL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type urlSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	URLs    []URL    `xml:"url"`
+}
+
+type index struct {
+	XMLName  xml.Name `xml:"sitemapindex"`
+	XMLNS    string   `xml:"xmlns,attr"`
+	Sitemaps []URL    `xml:"sitemap"`
+}
+
+// NewURL builds a sitemap entry, leaving lastmod out when it's unknown.
+func NewURL(loc string, lastMod time.Time) URL {
+	u := URL{Loc: loc}
+	if !lastMod.IsZero() {
+		u.LastMod = lastMod.UTC().Format(time.RFC3339)
+	}
+	return u
+}
+
+// Split chunks `urls` into groups of at most MaxURLs. It always returns at least one
+// (possibly empty) chunk.
+func Split(urls []URL) [][]URL {
+	if len(urls) <= MaxURLs {
+		return [][]URL{urls}
+	}
+
+	var chunks [][]URL
+	for len(urls) > 0 {
+		n := min(len(urls), MaxURLs)
+		chunks = append(chunks, urls[:n])
+		urls = urls[n:]
+	}
+	return chunks
+}
+
+// LastMod returns the most recent lastmod among `urls`, or "" if none have one.
+// RFC 3339 timestamps in UTC sort lexicographically, so a string comparison suffices.
+func LastMod(urls []URL) string {
+	var latest string
+	for _, u := range urls {
+		latest = max(latest, u.LastMod)
+	}
+	return latest
+}
+
+// EncodeURLSet renders a regular sitemap file.
+func EncodeURLSet(urls []URL) ([]byte, error) {
+	return encode(urlSet{XMLNS: namespace, URLs: urls})
+}
+
+// EncodeIndex renders a sitemap index pointing to the given sitemap files.
+func EncodeIndex(sitemaps []URL) ([]byte, error) {
+	return encode(index{XMLNS: namespace, Sitemaps: sitemaps})
+}
+
+func encode(doc any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,211 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/victhorio/jambe-verte/internal/logger"
+)
+
+// Kind identifies what a changed file is, so that consumers can rebuild only the
+// affected parts. Kinds are bit flags and combine within a single Change.
+type Kind int
+
+const (
+	Posts Kind = 1 << iota
+	Pages
+	Templates
+	CSS
+	Redirects
+)
+
+// Change is a debounced batch of file changes.
+type Change struct {
+	Kinds Kind
+	Paths []string
+}
+
+func (c Change) Has(kind Kind) bool {
+	return c.Kinds&kind != 0
+}
+
+func (c *Change) add(kind Kind, path string) {
+	c.Kinds |= kind
+	if !slices.Contains(c.Paths, path) {
+		c.Paths = append(c.Paths, path)
+	}
+}
+
+// Target is a set of files to watch: the entries of a single directory (not
+// recursive) whose base name matches Pattern, in filepath.Match syntax.
+type Target struct {
+	Kind    Kind
+	Dir     string
+	Pattern string
+}
+
+func (t Target) matches(path string) bool {
+	if filepath.Clean(filepath.Dir(path)) != filepath.Clean(t.Dir) {
+		return false
+	}
+	ok, _ := filepath.Match(t.Pattern, filepath.Base(path))
+	return ok
+}
+
+// Watcher reports changes to its targets through a callback. It uses inotify (or the
+// platform's equivalent) through fsnotify and falls back to polling file stats when
+// that's unavailable, e.g. when the inotify watch limit has been reached.
+type Watcher struct {
+	targets      []Target
+	debounce     time.Duration
+	pollInterval time.Duration
+	onChange     func(Change)
+}
+
+// New creates a watcher that calls `onChange` once events have been quiet for
+// `debounce`, so that an editor saving several files at once triggers a single rebuild.
+func New(targets []Target, debounce time.Duration, onChange func(Change)) *Watcher {
+	return &Watcher{
+		targets:      targets,
+		debounce:     debounce,
+		pollInterval: 500 * time.Millisecond,
+		onChange:     onChange,
+	}
+}
+
+// Run watches until `ctx` is cancelled. `onChange` is always called from the goroutine
+// running Run, so calls never overlap.
+func (w *Watcher) Run(ctx context.Context) {
+	fw, err := w.newNotifier()
+	if err != nil {
+		logger.Logger.WarnContext(ctx, "File notifications unavailable, falling back to polling", "error", err, "interval", w.pollInterval.String())
+		w.poll(ctx)
+		return
+	}
+	defer fw.Close()
+
+	logger.Logger.InfoContext(ctx, "Watching files for changes", "targets", len(w.targets))
+	w.notify(ctx, fw)
+}
+
+func (w *Watcher) newNotifier() (*fsnotify.Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// Watch directories rather than files so that editors replacing files through
+	// renames (and newly created files) are picked up too.
+	for _, t := range w.targets {
+		if err := fw.Add(t.Dir); err != nil {
+			fw.Close()
+			return nil, err
+		}
+	}
+	return fw, nil
+}
+
+// classify returns the kind of the target that `path` belongs to, if any.
+func (w *Watcher) classify(path string) (Kind, bool) {
+	for _, t := range w.targets {
+		if t.matches(path) {
+			return t.Kind, true
+		}
+	}
+	return 0, false
+}
+
+func (w *Watcher) notify(ctx context.Context, fw *fsnotify.Watcher) {
+	var pending Change
+
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+
+		case event, ok := <-fw.Events:
+			if !ok {
+				return
+			}
+			// Chmod events are pure noise (e.g. Spotlight or backup tools touching files)
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if kind, ok := w.classify(event.Name); ok {
+				pending.add(kind, event.Name)
+				timer.Reset(w.debounce)
+			}
+
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return
+			}
+			logger.Logger.WarnContext(ctx, "File watcher error", "error", err)
+
+		case <-timer.C:
+			w.onChange(pending)
+			pending = Change{}
+		}
+	}
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot stats every file currently matching the targets.
+func (w *Watcher) snapshot() map[string]fileState {
+	files := make(map[string]fileState)
+	for _, t := range w.targets {
+		paths, _ := filepath.Glob(filepath.Join(t.Dir, t.Pattern))
+		for _, path := range paths {
+			if info, err := os.Stat(path); err == nil {
+				files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			}
+		}
+	}
+	return files
+}
+
+func (w *Watcher) poll(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	previous := w.snapshot()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := w.snapshot()
+		var change Change
+		for path, state := range current {
+			if old, ok := previous[path]; !ok || old != state {
+				kind, _ := w.classify(path)
+				change.add(kind, path)
+			}
+		}
+		for path := range previous {
+			if _, ok := current[path]; !ok {
+				kind, _ := w.classify(path)
+				change.add(kind, path)
+			}
+		}
+		previous = current
+
+		if change.Kinds != 0 {
+			w.onChange(change)
+		}
+	}
+}